@@ -8,7 +8,7 @@ import (
 // osascript -e 'tell app "Safari" to make new document at end of documents with properties {URL:"http://naver.com"}'
 
 func launchWebBrowser() {
-	osascriptCommand := fmt.Sprintf("tell app \"safari\" to make new document at end of documents with properties {URL:\"http://localhost:%d\"}", port)
+	osascriptCommand := fmt.Sprintf("tell app \"safari\" to make new document at end of documents with properties {URL:\"http://%s\"}", localAddr())
 	cmd := exec.Command("osascript", "-e", osascriptCommand)
 
 	go func() {
@@ -24,6 +24,6 @@ func launchWebBrowser() {
 			return
 		}
 
-		logger.Infof("launch Safari to http://localhost:%d", port)
+		logger.Infof("launch Safari to http://%s", localAddr())
 	}()
 }
@@ -1,12 +1,11 @@
 package main
 
 import (
-	"fmt"
 	"os/exec"
 )
 
 func launchWebBrowser() {
-	cmd := exec.Command("cmd", "/C", "start", fmt.Sprintf("http://localhost:%d", port))
+	cmd := exec.Command("cmd", "/C", "start", "http://"+localAddr())
 
 	go func() {
 		err := cmd.Start()
@@ -21,6 +20,6 @@ func launchWebBrowser() {
 			return
 		}
 
-		logger.Infof("launch to http://localhost:%d", port)
+		logger.Infof("launch to http://%s", localAddr())
 	}()
 }
@@ -1,15 +1,14 @@
 package main
 
 import (
+	"carousel/config"
 	"carousel/renderer"
-	"carousel/server"
-	"carousel/static"
-	"flag"
 	"fmt"
 	"github.com/scryner/logg"
 	"net"
 	"os"
-	"path"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,128 +16,72 @@ const (
 	APP_NAME = "Carousel"
 	VERSION  = "0.2.1"
 
-	_DEFAULT_PORT      = 3999
-	_DEFAULT_LOG_LEVEL = logg.LOG_LEVEL_INFO
+	_DEFAULT_LOG_LEVEL      = logg.LOG_LEVEL_INFO
+	_DEFAULT_WATCH_INTERVAL = 500 * time.Millisecond
+
+	_FORMAT_AUTO = config.FormatAuto
 )
 
 var (
-	port          int
-	logFile       string
-	enableGzip    bool
-	verbose       bool
-	launchAtStart bool
+	// settings of config files overridden by flags given
+	cfg = config.Default()
 
-	playEnabled      bool
-	remotePlayground bool
+	configFile string
 
 	logger *logg.Logger
 )
 
-func init() {
-	flag.IntVar(&port, "p", _DEFAULT_PORT, "listen port")
-	flag.StringVar(&logFile, "log", "stderr", "specify log file (stdout/stderr means standard io)")
-	flag.BoolVar(&enableGzip, "z", true, "whether gzip supported or not")
-	flag.BoolVar(&launchAtStart, "l", false, "launch local web browser immediately")
-	flag.BoolVar(&verbose, "V", false, "logging verbosely")
-	flag.BoolVar(&playEnabled, "P", false, "enable go playground")
-	flag.BoolVar(&remotePlayground, "R", false, "go playground via Go official site")
-
-	flag.Usage = func() {
-		fmt.Printf("%s Version %s\n", APP_NAME, VERSION)
-		fmt.Printf("Usage: %s [options] filepath\n", os.Args[0])
-		fmt.Println("Options are:")
-
-		flag.PrintDefaults()
-	}
-}
-
 func main() {
-	flag.Parse()
-
-	// getting input file path
-	args := flag.Args()
-
-	if len(args) < 1 {
-		flag.Usage()
-		os.Exit(1)
+	args := os.Args[1:]
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		os.Exit(exitUsage)
 	}
 
-	inputFile := args[0]
-
-	// initializing logger
-	defer func() {
-		logg.Flush()
-		os.Exit(1)
-	}()
-
-	var logLevel logg.LogLevel
-
-	if verbose {
-		logLevel = logg.LOG_LEVEL_DEBUG
-	} else {
-		logLevel = _DEFAULT_LOG_LEVEL
-	}
-
-	switch logFile {
-	case "stdout":
-		logg.SetDefaultLogger(os.Stdout, logLevel)
-	case "stderr":
-		logg.SetDefaultLogger(os.Stderr, logLevel)
+	cmd := commandOf(args[0])
+	switch {
+	case cmd != nil:
+		args = args[1:]
+	case args[0] == "-h" || args[0] == "-help" || args[0] == "--help":
+		printUsage(os.Stdout)
+		os.Exit(exitOK)
+	case isServeArg(args[0]):
+		// serving is the default, to keep working as before commands
+		cmd = serveCommand
 	default:
-		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			logg.SetDefaultLogger(os.Stderr, logLevel)
-		} else {
-			logg.SetDefaultLogger(f, logLevel)
-			defer f.Close()
-		}
+		fmt.Fprintf(os.Stderr, "%s: unknown command '%s'\n", os.Args[0], args[0])
+		fmt.Fprintf(os.Stderr, "Run '%s help' for usage.\n", os.Args[0])
+		os.Exit(exitUsage)
 	}
 
-	logger = logg.GetDefaultLogger("main")
-
-	// initializing static file list
-	staticFiles := make(map[string]server.StaticContent)
-	staticFiles["/static/slides.js"] = server.StaticContent{"text/javascript", static.Slides_js}
-	staticFiles["/static/print.css"] = server.StaticContent{"text/css", static.Print_css}
-	staticFiles["/static/styles.css"] = server.StaticContent{"text/css", static.Styles_css}
-
-	if playEnabled {
-		logger.Infof("Go playground enabled")
+	os.Exit(cmd.execute(args))
+}
 
-		if remotePlayground {
-			logger.Infof("\t: to Go official playground by HTTP")
-			staticFiles["/static/play.js"] = server.StaticContent{"text/javascript", static.Play_js + "\ninitPlayground(new HTTPTransport());\n"}
-		} else {
-			logger.Infof("\t: to local playground by WebSocket")
-			staticFiles["/static/play.js"] = server.StaticContent{"text/javascript", static.Play_js + "\ninitPlayground(new SocketTransport());\n"}
-		}
-	} else {
-		logger.Infof("Go playground disabled")
+// isServeArg tells whether arg, which is not a command, starts arguments
+// of serve: a flag or a path of slides. A word which is neither is taken
+// for a mistyped command.
+func isServeArg(arg string) bool {
+	if strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "./\\") {
+		return true
 	}
 
-	workingPath := path.Dir(inputFile)
-
-	var rend renderer.Renderer
-	rend = renderer.NewFileRenderer(inputFile, playEnabled)
-
-	// initializing server
-	srv := server.NewServer(port, enableGzip, workingPath, rend, staticFiles)
+	_, err := os.Stat(arg)
+	return err == nil
+}
 
-	// trying to launch web browser
-	if launchAtStart {
-		go tryLaunchWebBrowser()
+// formatOf returns the input format of the file; format given by the flag
+// overrides the one guessed by the extension.
+func formatOf(filename, format string) string {
+	if format == _FORMAT_AUTO {
+		return renderer.FormatOf(filename)
 	}
 
-    // print logo
-    fmt.Printf(asciiLogo, VERSION);
-
-	// starting server
-	srv.Start()
+	return format
 }
 
 func tryLaunchWebBrowser() {
 	for {
-		c, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		c, err := net.Dial("tcp", localAddr())
 		if err == nil {
 			c.Close()
 			break
@@ -149,14 +92,24 @@ func tryLaunchWebBrowser() {
 	launchWebBrowser()
 }
 
+// localAddr returns the address of the server to be opened on this host.
+func localAddr() string {
+	host := cfg.Bind
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+}
+
 // Actually below codes are not needed any more
 func getSocketAddr() string {
 	_, localIp, err := getHostnameAndLocalIpAddress()
 	if err != nil {
-		return fmt.Sprintf("ws://localhost:%d/socket", port)
+		return fmt.Sprintf("ws://localhost:%d/socket", cfg.Port)
 	}
 
-	return fmt.Sprintf("ws://%s:%d/socket", localIp, port)
+	return fmt.Sprintf("ws://%s:%d/socket", localIp, cfg.Port)
 }
 
 func getHostnameAndLocalIpAddress() (hostname, localIp string, err error) {
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"carousel/config"
+	"carousel/renderer"
+	"carousel/theme"
+	"flag"
+	"fmt"
+	"github.com/scryner/logg"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exit codes of commands, which scripts can rely on
+const (
+	exitOK       = 0
+	exitFailure  = 1 // the command couldn't do its work
+	exitUsage    = 2 // arguments or flags are wrong
+	exitProblems = 3 // slides were checked, and problems were found
+)
+
+// command is a subcommand of carousel. flags adds its flags to the flag
+// set, and run does the work with the flags parsed, returning the exit code.
+type command struct {
+	name  string
+	args  string // synopsis of arguments after options
+	short string // one line description for the list of commands
+	long  string // description for the help of the command
+
+	flags func(fs *flag.FlagSet)
+	run   func(cmd *command, fs *flag.FlagSet) int
+}
+
+// commands are listed in the usage in this order. It is filled in init to
+// break the cycle through help.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		serveCommand,
+		exportCommand,
+		lintCommand,
+		recordCommand,
+		convertCommand,
+		newCommand,
+		fmtCommand,
+		lspCommand,
+		helpCommand,
+	}
+}
+
+func commandOf(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+
+	return nil
+}
+
+// flagSet returns the flag set of the command, whose usage is the help of
+// the command.
+func (cmd *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+
+	fs.Usage = func() {
+		cmd.printHelp(os.Stderr, fs)
+	}
+
+	return fs
+}
+
+// execute runs the command with args, and returns the exit code. Asking
+// for help by -h is not an error.
+func (cmd *command) execute(args []string) int {
+	fs := cmd.flagSet()
+
+	err := fs.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return exitOK
+	case err != nil:
+		return exitUsage
+	}
+
+	return cmd.run(cmd, fs)
+}
+
+// usageError reports wrong arguments, and returns the exit code for them.
+func (cmd *command) usageError(format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "%s %s: %s\n", os.Args[0], cmd.name, fmt.Sprintf(format, args...))
+	fmt.Fprintf(os.Stderr, "Run '%s help %s' for usage.\n", os.Args[0], cmd.name)
+
+	return exitUsage
+}
+
+func (cmd *command) printHelp(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %s %s", os.Args[0], cmd.name)
+	if cmd.flags != nil {
+		fmt.Fprint(w, " [options]")
+	}
+	fmt.Fprintf(w, " %s\n\n", cmd.args)
+
+	fmt.Fprintln(w, strings.TrimSpace(cmd.long))
+
+	if cmd.flags != nil {
+		fmt.Fprintln(w, "\nOptions are:")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "%s Version %s\n", APP_NAME, VERSION)
+	fmt.Fprintf(w, "Usage: %s <command> [options] [arguments]\n", os.Args[0])
+	fmt.Fprintf(w, "       %s [options] filepath|directory (same as serve)\n", os.Args[0])
+
+	fmt.Fprintln(w, "\nCommands are:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.short)
+	}
+
+	fmt.Fprintf(w, "\nRun '%s help <command>' for options of the command.\n", os.Args[0])
+
+	fmt.Fprintf(w, "\nSettings are also read from %s in the directory of slides, and then\n", config.FileName)
+	fmt.Fprintln(w, "from the user config; flags override both, and the former overrides the latter.")
+
+	fmt.Fprintln(w, "\nExit codes are:")
+	fmt.Fprintf(w, "  %d  success\n", exitOK)
+	fmt.Fprintf(w, "  %d  failure, such as a file which can't be read or written\n", exitFailure)
+	fmt.Fprintf(w, "  %d  wrong command, arguments or flags\n", exitUsage)
+	fmt.Fprintf(w, "  %d  problems found by lint, files not formatted for fmt -l and -d, or snippets failed for record\n", exitProblems)
+}
+
+var helpCommand = &command{
+	name:  "help",
+	args:  "[command]",
+	short: "show help of carousel or a command",
+	long: `
+Help shows options of the command, or commands of carousel if no command
+is given.`,
+	run: runHelp,
+}
+
+func runHelp(cmd *command, fs *flag.FlagSet) int {
+	if fs.NArg() == 0 {
+		printUsage(os.Stdout)
+		return exitOK
+	}
+
+	c := commandOf(fs.Arg(0))
+	if c == nil {
+		return cmd.usageError("unknown command '%s'", fs.Arg(0))
+	}
+
+	c.printHelp(os.Stdout, c.flagSet())
+	return exitOK
+}
+
+// Flags and settings shared by commands reading slides.
+
+// inputFlags adds flags of how slides are read to fs. They are bound to
+// cfg, and applied again over config files by loadConfig.
+func inputFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Format, "f", cfg.Format, "input format: auto (by file extension), present or markdown")
+	fs.StringVar(&cfg.Encoding, "encoding", cfg.Encoding, "encoding of slides: "+renderer.EncodingAuto+", "+strings.Join(renderer.Encodings(), ", ")+" (#encoding directive of slides overrides it)")
+	fs.StringVar(&configFile, "config", "", "config file used instead of the user config ("+config.UserFile()+")")
+}
+
+func themeFlag(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Theme, "theme", cfg.Theme, "theme of slides: "+strings.Join(theme.Builtins(), ", ")+" or a directory (overrides #theme directive of slides)")
+}
+
+func logFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Log, "log", cfg.Log, "specify log file (stdout/stderr means standard io)")
+	fs.BoolVar(&cfg.Verbose, "V", cfg.Verbose, "logging verbosely")
+}
+
+// loadConfig reads config files for the slides of inputPath, and applies
+// flags given to fs again over them.
+func loadConfig(fs *flag.FlagSet, inputPath string) error {
+	// flags point to fields of cfg
+	return cfg.LoadWithFlags(fs, configFile, inputPath)
+}
+
+// checkInput checks settings of how slides are read, which may come from
+// config files as well as flags.
+func checkInput() error {
+	switch cfg.Format {
+	case _FORMAT_AUTO, renderer.FormatPresent, renderer.FormatMarkdown:
+	default:
+		return fmt.Errorf("unknown input format '%s'", cfg.Format)
+	}
+
+	if err := renderer.CheckEncoding(cfg.Encoding); err != nil {
+		return err
+	}
+
+	if cfg.Theme != "" {
+		if _, err := theme.Load(cfg.Theme, "."); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupLogging sets the default logger by cfg. The returned function must
+// be called before exiting, to write out logs.
+func setupLogging() func() {
+	var logLevel logg.LogLevel
+
+	if cfg.Verbose {
+		logLevel = logg.LOG_LEVEL_DEBUG
+	} else {
+		logLevel = _DEFAULT_LOG_LEVEL
+	}
+
+	cleanup := logg.Flush
+
+	switch cfg.Log {
+	case "stdout":
+		logg.SetDefaultLogger(os.Stdout, logLevel)
+	case "stderr":
+		logg.SetDefaultLogger(os.Stderr, logLevel)
+	default:
+		f, err := os.OpenFile(cfg.Log, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logg.SetDefaultLogger(os.Stderr, logLevel)
+		} else {
+			logg.SetDefaultLogger(f, logLevel)
+			cleanup = func() {
+				logg.Flush()
+				f.Close()
+			}
+		}
+	}
+
+	logger = logg.GetDefaultLogger("main")
+
+	for _, file := range cfg.Sources {
+		logger.Infof("Config read from '%s'", file)
+	}
+
+	return cleanup
+}
+
+// slideFiles returns the files given, replacing directories by slides of
+// the present format in them.
+func slideFiles(paths []string) ([]string, error) {
+	return findSlides(paths, false)
+}
+
+// deckFiles is slideFiles replacing directories by slides in Markdown as
+// well, which are told by their extensions as renderer.FormatOf does.
+func deckFiles(paths []string) ([]string, error) {
+	return findSlides(paths, true)
+}
+
+func findSlides(paths []string, markdown bool) ([]string, error) {
+	var files []string
+
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !fi.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			// skip hidden directories such as .git
+			if fi.IsDir() && p != path && strings.HasPrefix(fi.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			if !fi.IsDir() && renderer.IsDeckFile(p) && (markdown || renderer.FormatOf(p) == renderer.FormatPresent) {
+				files = append(files, p)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
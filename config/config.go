@@ -0,0 +1,275 @@
+// Package config reads settings of carousel from files, so that they need
+// not be given by flags every time.
+package config
+
+import (
+	"bytes"
+	"carousel/renderer"
+	"carousel/theme"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileName is the name of config files in deck and user directories.
+const FileName = "carousel.json"
+
+const (
+	DefaultPort         = 3999
+	DefaultDrainTimeout = 5 * time.Second
+	FormatAuto          = "auto"
+)
+
+// Config is the settings of carousel. Fields missing in a file keep the
+// values of files of lower precedence.
+type Config struct {
+	Port         int      `json:"port"`
+	Bind         string   `json:"bind"` // address to listen on; empty means all
+	Gzip         bool     `json:"gzip"`
+	Log          string   `json:"log"` // file, stdout or stderr
+	Verbose      bool     `json:"verbose"`
+	Launch       bool     `json:"launch"`
+	Watch        bool     `json:"watch"`
+	Format       string   `json:"format"`
+	Theme        string   `json:"theme"`
+	Encoding     string   `json:"encoding"`
+	Play         bool     `json:"play"`
+	RemotePlay   bool     `json:"remotePlay"`
+	Replay       bool     `json:"replay"` // replay output recorded instead of running snippets
+	DrainTimeout Duration `json:"drainTimeout"`
+	Templates    string   `json:"templates"` // directory of templates for new slides
+	PlayLimits   Limits   `json:"playLimits"`
+	Runners      []string `json:"runners"`   // of languages other than Go run by the playground
+	PlayCache    string   `json:"playCache"` // directory of binaries of snippets; empty not to cache
+
+	// Sources has config files read, from the lowest precedence.
+	Sources []string `json:"-"`
+}
+
+// Default returns the settings used when nothing is given.
+func Default() Config {
+	var templates string
+	if dir := UserDir(); dir != "" {
+		templates = filepath.Join(dir, "templates")
+	}
+
+	var playCache string
+	if dir := CacheDir(); dir != "" {
+		playCache = filepath.Join(dir, "play")
+	}
+
+	return Config{
+		Port:         DefaultPort,
+		Gzip:         true,
+		Log:          "stderr",
+		Watch:        true,
+		Format:       FormatAuto,
+		Encoding:     renderer.EncodingAuto,
+		DrainTimeout: Duration(DefaultDrainTimeout),
+		Templates:    templates,
+		PlayCache:    playCache,
+		PlayLimits: Limits{
+			Timeout:   Duration(10 * time.Second),
+			CPU:       Duration(10 * time.Second),
+			MemoryMB:  512,
+			OpenFiles: 256,
+			OutputKB:  1024,
+			Runs:      2,
+		},
+	}
+}
+
+// Limits limit each run of the local playground, whose machine may be
+// shared. Zero means no limit.
+type Limits struct {
+	Timeout   Duration `json:"timeout"` // wall-clock time of running a program
+	CPU       Duration `json:"cpu"`
+	MemoryMB  int      `json:"memoryMB"`
+	OpenFiles int      `json:"openFiles"`
+	OutputKB  int      `json:"outputKB"`
+	Runs      int      `json:"runs"` // programs run at once; more wait
+}
+
+// Load returns the default settings overridden by the user config, and
+// then by the config next to the slides of inputPath. file replaces the
+// user config if not empty; it must exist while the others may not.
+func Load(file, inputPath string) (Config, error) {
+	c := Default()
+
+	if file != "" {
+		if err := c.read(file); err != nil {
+			return c, err
+		}
+	} else if user := UserFile(); user != "" {
+		if err := c.readIfExists(user); err != nil {
+			return c, err
+		}
+	}
+
+	if inputPath != "" {
+		if err := c.readIfExists(DeckFile(inputPath)); err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
+}
+
+// LoadWithFlags loads settings into c as Load does, keeping values of flags
+// given in fs, which point to fields of c.
+func (c *Config) LoadWithFlags(fs *flag.FlagSet, file, inputPath string) error {
+	given := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	loaded, err := Load(file, inputPath)
+	if err != nil {
+		return err
+	}
+
+	*c = loaded
+	for name, value := range given {
+		fs.Set(name, value)
+	}
+
+	return nil
+}
+
+// UserDir returns the directory of the user config under XDG_CONFIG_HOME,
+// or ~/.config if it is not set. It is empty if neither is known.
+func UserDir() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "carousel")
+}
+
+// CacheDir returns the directory of caches under XDG_CACHE_HOME, or
+// ~/.cache if it is not set. It is empty if neither is known.
+func CacheDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home := os.Getenv("HOME")
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+
+	return filepath.Join(dir, "carousel")
+}
+
+// UserFile returns the path of the user config, or an empty string if the
+// directory of it is not known.
+func UserFile() string {
+	dir := UserDir()
+	if dir == "" {
+		return ""
+	}
+
+	return filepath.Join(dir, FileName)
+}
+
+// DeckFile returns the path of the config for the slides of inputPath,
+// which is either a file or a directory of slides.
+func DeckFile(inputPath string) string {
+	if fi, err := os.Stat(inputPath); err == nil && fi.IsDir() {
+		return filepath.Join(inputPath, FileName)
+	}
+
+	return filepath.Join(filepath.Dir(inputPath), FileName)
+}
+
+func (c *Config) readIfExists(file string) error {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return nil
+	}
+
+	return c.read(file)
+}
+
+func (c *Config) read(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	// directories are relative to the file giving them
+	themeBefore, templatesBefore, playCacheBefore := c.Theme, c.Templates, c.PlayCache
+	c.Theme, c.Templates, c.PlayCache = "", "", ""
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("while reading config '%s': %v", file, err)
+	}
+
+	if c.Theme == "" {
+		c.Theme = themeBefore
+	} else if !theme.IsBuiltin(c.Theme) && !filepath.IsAbs(c.Theme) {
+		c.Theme = filepath.Join(filepath.Dir(file), c.Theme)
+	}
+
+	if c.Templates == "" {
+		c.Templates = templatesBefore
+	} else if !filepath.IsAbs(c.Templates) {
+		c.Templates = filepath.Join(filepath.Dir(file), c.Templates)
+	}
+
+	if c.PlayCache == "" {
+		c.PlayCache = playCacheBefore
+	} else if !filepath.IsAbs(c.PlayCache) {
+		c.PlayCache = filepath.Join(filepath.Dir(file), c.PlayCache)
+	}
+
+	c.Sources = append(c.Sources, file)
+	return nil
+}
+
+// JSON returns the settings in the format of config files.
+func (c Config) JSON() []byte {
+	b, _ := json.MarshalIndent(c, "", "  ")
+	return append(b, '\n')
+}
+
+// Duration is time.Duration written as "5s" in config files. It can be a
+// value of flags as well.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\"")
+	}
+
+	return d.Set(s)
+}
@@ -0,0 +1,342 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configDirs makes directories of the user config and of slides, with the
+// config files given. An empty content makes no file.
+type configDirs struct {
+	root, user, deck, custom string
+}
+
+func newConfigDirs(t *testing.T, user, deck, custom string) *configDirs {
+	root, err := ioutil.TempDir("", "carousel-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &configDirs{
+		root:   root,
+		user:   filepath.Join(root, "home", "carousel"),
+		deck:   filepath.Join(root, "talks"),
+		custom: filepath.Join(root, "custom"),
+	}
+
+	for dir, content := range map[string]string{d.user: user, d.deck: deck, d.custom: custom} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if content == "" {
+			continue
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, FileName), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(d.deck, "talk.slide"), []byte("Talk\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func (d *configDirs) slides() string {
+	return filepath.Join(d.deck, "talk.slide")
+}
+
+func (d *configDirs) customFile() string {
+	return filepath.Join(d.custom, FileName)
+}
+
+// setenv sets the variable for the test, and returns the function restoring
+// it.
+func setenv(key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name             string
+		user, deck       string
+		custom           string // given by -config
+		args             []string
+		wantPort         int
+		wantFormat       string
+		wantVerbose      bool
+		wantDrainTimeout time.Duration
+		wantSources      []string // of user, deck and custom
+	}{
+		{
+			name:             "defaults",
+			wantPort:         DefaultPort,
+			wantFormat:       FormatAuto,
+			wantDrainTimeout: DefaultDrainTimeout,
+		},
+		{
+			name:             "user",
+			user:             `{"port": 4000, "verbose": true}`,
+			wantPort:         4000,
+			wantFormat:       FormatAuto,
+			wantVerbose:      true,
+			wantDrainTimeout: DefaultDrainTimeout,
+			wantSources:      []string{"user"},
+		},
+		{
+			name:             "deck over user",
+			user:             `{"port": 4000, "format": "markdown"}`,
+			deck:             `{"port": 5000, "drainTimeout": "1s"}`,
+			wantPort:         5000,
+			wantFormat:       "markdown",
+			wantDrainTimeout: time.Second,
+			wantSources:      []string{"user", "deck"},
+		},
+		{
+			name:             "custom instead of user",
+			user:             `{"port": 4000, "verbose": true}`,
+			custom:           `{"format": "present"}`,
+			wantPort:         DefaultPort,
+			wantFormat:       "present",
+			wantDrainTimeout: DefaultDrainTimeout,
+			wantSources:      []string{"custom"},
+		},
+		{
+			name:             "deck over custom",
+			custom:           `{"port": 4000, "format": "present"}`,
+			deck:             `{"port": 5000}`,
+			wantPort:         5000,
+			wantFormat:       "present",
+			wantDrainTimeout: DefaultDrainTimeout,
+			wantSources:      []string{"custom", "deck"},
+		},
+		{
+			name:             "flags over all",
+			user:             `{"port": 4000, "verbose": true}`,
+			deck:             `{"port": 5000, "format": "markdown"}`,
+			args:             []string{"-p", "6000", "-V=false"},
+			wantPort:         6000,
+			wantFormat:       "markdown",
+			wantDrainTimeout: DefaultDrainTimeout,
+			wantSources:      []string{"user", "deck"},
+		},
+		{
+			name:             "flags of default values",
+			deck:             `{"port": 5000}`,
+			args:             []string{"-p", "3999", "-drain", "2s"},
+			wantPort:         DefaultPort,
+			wantFormat:       FormatAuto,
+			wantDrainTimeout: 2 * time.Second,
+			wantSources:      []string{"deck"},
+		},
+	}
+
+	for _, test := range tests {
+		d := newConfigDirs(t, test.user, test.deck, test.custom)
+		restore := setenv("XDG_CONFIG_HOME", filepath.Join(d.root, "home"))
+
+		var configFile string
+		if test.custom != "" {
+			configFile = d.customFile()
+		}
+
+		c := Default()
+		fs := flag.NewFlagSet(test.name, flag.ContinueOnError)
+		fs.IntVar(&c.Port, "p", c.Port, "")
+		fs.BoolVar(&c.Verbose, "V", c.Verbose, "")
+		fs.Var(&c.DrainTimeout, "drain", "")
+
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		err := c.LoadWithFlags(fs, configFile, d.slides())
+
+		restore()
+		os.RemoveAll(d.root)
+
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if c.Port != test.wantPort {
+			t.Errorf("%s: port %d; want %d", test.name, c.Port, test.wantPort)
+		}
+		if c.Format != test.wantFormat {
+			t.Errorf("%s: format %q; want %q", test.name, c.Format, test.wantFormat)
+		}
+		if c.Verbose != test.wantVerbose {
+			t.Errorf("%s: verbose %v; want %v", test.name, c.Verbose, test.wantVerbose)
+		}
+		if time.Duration(c.DrainTimeout) != test.wantDrainTimeout {
+			t.Errorf("%s: drain timeout %v; want %v", test.name, c.DrainTimeout, test.wantDrainTimeout)
+		}
+
+		var sources []string
+		for _, file := range c.Sources {
+			switch filepath.Dir(file) {
+			case d.user:
+				sources = append(sources, "user")
+			case d.deck:
+				sources = append(sources, "deck")
+			case d.custom:
+				sources = append(sources, "custom")
+			default:
+				sources = append(sources, file)
+			}
+		}
+		if !reflect.DeepEqual(sources, test.wantSources) {
+			t.Errorf("%s: sources %v; want %v", test.name, sources, test.wantSources)
+		}
+	}
+}
+
+func TestLoadRelativePaths(t *testing.T) {
+	abs := filepath.Join(os.TempDir(), "abs")
+
+	tests := []struct {
+		name          string
+		user, deck    string
+		wantTheme     string // relative to the root of directories
+		wantTemplates string
+		wantPlayCache string
+	}{
+		{
+			name:          "user",
+			user:          `{"theme": "mytheme", "templates": "tmpl", "playCache": "../cache"}`,
+			wantTheme:     "home/carousel/mytheme",
+			wantTemplates: "home/carousel/tmpl",
+			wantPlayCache: "home/cache",
+		},
+		{
+			name:          "deck",
+			user:          `{"theme": "mytheme", "templates": "tmpl", "playCache": "cache"}`,
+			deck:          `{"theme": "theme", "playCache": "cache"}`,
+			wantTheme:     "talks/theme",
+			wantTemplates: "home/carousel/tmpl",
+			wantPlayCache: "talks/cache",
+		},
+		{
+			name:          "built-in theme",
+			deck:          `{"theme": "dark", "templates": "tmpl"}`,
+			wantTheme:     "dark",
+			wantTemplates: "talks/tmpl",
+		},
+		{
+			name:          "absolute",
+			deck:          `{"theme": "` + abs + `", "templates": "` + abs + `", "playCache": "` + abs + `"}`,
+			wantTheme:     abs,
+			wantTemplates: abs,
+			wantPlayCache: abs,
+		},
+	}
+
+	for _, test := range tests {
+		d := newConfigDirs(t, test.user, test.deck, "")
+		restore := setenv("XDG_CONFIG_HOME", filepath.Join(d.root, "home"))
+		restoreCache := setenv("XDG_CACHE_HOME", filepath.Join(d.root, "cache"))
+
+		c, err := Load("", d.slides())
+
+		restoreCache()
+		restore()
+		os.RemoveAll(d.root)
+
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		want := func(p string) string {
+			switch {
+			case p == "":
+				return ""
+			case p == "dark" || filepath.IsAbs(p):
+				return p
+			}
+			return filepath.Join(d.root, filepath.FromSlash(p))
+		}
+
+		if c.Theme != want(test.wantTheme) {
+			t.Errorf("%s: theme %q; want %q", test.name, c.Theme, want(test.wantTheme))
+		}
+		if test.wantTemplates != "" && c.Templates != want(test.wantTemplates) {
+			t.Errorf("%s: templates %q; want %q", test.name, c.Templates, want(test.wantTemplates))
+		}
+
+		// defaults to the cache directory if no file gives it
+		wantPlayCache := want(test.wantPlayCache)
+		if wantPlayCache == "" {
+			wantPlayCache = filepath.Join(d.root, "cache", "carousel", "play")
+		}
+		if c.PlayCache != wantPlayCache {
+			t.Errorf("%s: play cache %q; want %q", test.name, c.PlayCache, wantPlayCache)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		user, deck string
+		custom     bool // -config of a missing file
+		wantErr    string
+	}{
+		{
+			name:    "unknown field of user",
+			user:    `{"prot": 4000}`,
+			wantErr: `unknown field "prot"`,
+		},
+		{
+			name:    "unknown field of deck",
+			deck:    `{"playLimits": {"memory": 128}}`,
+			wantErr: `unknown field "memory"`,
+		},
+		{
+			name:    "bad duration",
+			deck:    `{"drainTimeout": 5}`,
+			wantErr: "duration must be a string",
+		},
+		{
+			name:    "missing config",
+			custom:  true,
+			wantErr: "no such file",
+		},
+	}
+
+	for _, test := range tests {
+		d := newConfigDirs(t, test.user, test.deck, "")
+		restore := setenv("XDG_CONFIG_HOME", filepath.Join(d.root, "home"))
+
+		var configFile string
+		if test.custom {
+			configFile = d.customFile()
+		}
+
+		_, err := Load(configFile, d.slides())
+
+		restore()
+		os.RemoveAll(d.root)
+
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: error %v; want one of %s", test.name, err, test.wantErr)
+		}
+	}
+}
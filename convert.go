@@ -0,0 +1,163 @@
+package main
+
+import (
+	"carousel/renderer"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var convertCommand = &command{
+	name:  "convert",
+	args:  "filepath",
+	short: "convert slides between the present and Markdown formats",
+	long: `
+Convert writes the slides in the other format: Markdown slides become the
+present format, and slides of the present format become Markdown.
+
+The present format refers to code of snippets by files, so snippets of
+Markdown are saved next to the output as <output name>-<n>.<ext>. They
+become indented blocks if the output is the standard output. What the
+output format can't express is dropped with a warning.`,
+	flags: convertFlags,
+	run:   runConvert,
+}
+
+var (
+	convertTo     string
+	convertOutput string
+)
+
+func convertFlags(fs *flag.FlagSet) {
+	fs.StringVar(&convertTo, "to", "", "output format: present or markdown (default is the other format of the input)")
+	fs.StringVar(&convertOutput, "o", "", "output file (default is input file name with the extension of the output format, - means stdout)")
+
+	inputFlags(fs)
+	logFlags(fs)
+}
+
+func runConvert(cmd *command, fs *flag.FlagSet) int {
+	if fs.NArg() != 1 {
+		return cmd.usageError("one file of slides must be given")
+	}
+
+	inputFile := fs.Arg(0)
+
+	if err := loadConfig(fs, inputFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	cleanup := setupLogging()
+	defer cleanup()
+
+	if err := checkInput(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	from := formatOf(inputFile, cfg.Format)
+
+	to := convertTo
+	switch to {
+	case "":
+		if from == renderer.FormatMarkdown {
+			to = renderer.FormatPresent
+		} else {
+			to = renderer.FormatMarkdown
+		}
+	case renderer.FormatPresent, renderer.FormatMarkdown:
+	default:
+		return cmd.usageError("unknown output format '%s'", to)
+	}
+
+	outputFile := convertOutput
+	if outputFile == "" {
+		ext := ".slide"
+		if to == renderer.FormatMarkdown {
+			ext = ".md"
+		}
+		outputFile = strings.TrimSuffix(inputFile, filepath.Ext(inputFile)) + ext
+	}
+
+	if outputFile == "-" {
+		warnings, err := renderer.Convert(os.Stdout, inputFile, from, to, cfg.Encoding, nil)
+		printWarnings(inputFile, warnings)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to convert '%s': %v\n", inputFile, err)
+			return exitFailure
+		}
+
+		return exitOK
+	}
+
+	if sameFile(inputFile, outputFile) {
+		fmt.Fprintf(os.Stderr, "output '%s' would overwrite the input\n", outputFile)
+		return exitFailure
+	}
+
+	f, err := os.Create(outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create '%s': %v\n", outputFile, err)
+		return exitFailure
+	}
+
+	warnings, err := renderer.Convert(f, inputFile, from, to, cfg.Encoding, snippetSaver(outputFile))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	printWarnings(inputFile, warnings)
+
+	if err != nil {
+		os.Remove(outputFile)
+		fmt.Fprintf(os.Stderr, "failed to convert '%s': %v\n", inputFile, err)
+		return exitFailure
+	}
+
+	fmt.Printf("converted to %s\n", outputFile)
+	return exitOK
+}
+
+// snippetSaver returns the saver writing snippets next to outputFile, and
+// referring them relatively to it.
+func snippetSaver(outputFile string) renderer.SnippetSaver {
+	dir := filepath.Dir(outputFile)
+	base := strings.TrimSuffix(filepath.Base(outputFile), filepath.Ext(outputFile))
+
+	return func(n int, ext string, code []byte) (string, error) {
+		if ext == "" {
+			ext = ".txt"
+		}
+
+		name := fmt.Sprintf("%s-%d%s", base, n, ext)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), code, 0644); err != nil {
+			return "", err
+		}
+
+		return name, nil
+	}
+}
+
+func printWarnings(file string, warnings []string) {
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", file, w)
+	}
+}
+
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(fa, fb)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"carousel/renderer"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var exportCommand = &command{
+	name:  "export",
+	args:  "filepath",
+	short: "write slides into a single HTML file",
+	long: `
+Export writes the slides into a single HTML file which can be opened
+without a server. Images, styles and scripts are embedded in the file.`,
+	flags: exportFlags,
+	run:   runExport,
+}
+
+var exportOutput string
+
+func exportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&exportOutput, "o", "", "output file (default is input file name with .html extension, - means stdout)")
+
+	inputFlags(fs)
+	themeFlag(fs)
+	logFlags(fs)
+}
+
+func runExport(cmd *command, fs *flag.FlagSet) int {
+	if fs.NArg() != 1 {
+		return cmd.usageError("one file of slides must be given")
+	}
+
+	inputFile := fs.Arg(0)
+
+	if err := loadConfig(fs, inputFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	cleanup := setupLogging()
+	defer cleanup()
+
+	if err := checkInput(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	inputFormat := formatOf(inputFile, cfg.Format)
+
+	outputFile := exportOutput
+	if outputFile == "" {
+		outputFile = strings.TrimSuffix(inputFile, filepath.Ext(inputFile)) + ".html"
+	}
+
+	if outputFile == "-" {
+		if err := renderer.Export(os.Stdout, inputFile, inputFormat, cfg.Theme, cfg.Encoding); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export '%s': %v\n", inputFile, err)
+			return exitFailure
+		}
+
+		return exitOK
+	}
+
+	f, err := os.Create(outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create '%s': %v\n", outputFile, err)
+		return exitFailure
+	}
+
+	err = renderer.Export(f, inputFile, inputFormat, cfg.Theme, cfg.Encoding)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(outputFile)
+		fmt.Fprintf(os.Stderr, "failed to export '%s': %v\n", inputFile, err)
+		return exitFailure
+	}
+
+	fmt.Printf("exported to %s\n", outputFile)
+	return exitOK
+}
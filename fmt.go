@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"carousel/format"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+var fmtCommand = &command{
+	name:  "fmt",
+	args:  "[filepath|directory...]",
+	short: "format slides of the present format canonically",
+	long: `
+Fmt formats slides of the present format canonically: trailing whitespace
+and runs of blank lines are removed, headings have a blank line before
+them and indented blocks are indented by a tab. Slides mean the same after
+formatting, and comments are kept where they are.
+
+Formatted slides are printed unless -l, -d or -w is given. Without files,
+slides are read from the standard input.
+
+With -l or -d, fmt exits with 3 if any file isn't formatted.`,
+	flags: fmtFlags,
+	run:   runFmt,
+}
+
+var (
+	fmtList  bool
+	fmtDiff  bool
+	fmtWrite bool
+)
+
+func fmtFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&fmtList, "l", false, "list files whose formatting differs")
+	fs.BoolVar(&fmtDiff, "d", false, "print diffs instead of formatted slides")
+	fs.BoolVar(&fmtWrite, "w", false, "write formatted slides to the files")
+}
+
+func runFmt(cmd *command, fs *flag.FlagSet) int {
+	if fs.NArg() == 0 {
+		if fmtWrite {
+			return cmd.usageError("-w needs files")
+		}
+
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+
+		differs, err := fmtSource("<standard input>", src)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+
+		return fmtExit(false, differs)
+	}
+
+	files, err := slideFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	failed, differs := false, false
+
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err == nil {
+			var d bool
+			d, err = fmtSource(file, src)
+			differs = differs || d
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+
+	return fmtExit(failed, differs)
+}
+
+func fmtExit(failed, differs bool) int {
+	switch {
+	case failed:
+		return exitFailure
+	case differs && (fmtList || fmtDiff):
+		return exitProblems
+	}
+
+	return exitOK
+}
+
+// fmtSource formats src of the file as the flags say, and tells whether
+// its formatting differs.
+func fmtSource(file string, src []byte) (bool, error) {
+	res, err := format.Source(src)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", file, err)
+	}
+
+	differs := !bytes.Equal(src, res)
+
+	if fmtList && differs {
+		fmt.Println(file)
+	}
+
+	if fmtWrite && differs {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return differs, err
+		}
+		if err := ioutil.WriteFile(file, res, fi.Mode().Perm()); err != nil {
+			return differs, err
+		}
+	}
+
+	if fmtDiff && differs {
+		d, err := diff(file, src, res)
+		if err != nil {
+			return differs, fmt.Errorf("computing diff of %s: %v", file, err)
+		}
+		os.Stdout.Write(d)
+	}
+
+	if !fmtList && !fmtWrite && !fmtDiff {
+		os.Stdout.Write(res)
+	}
+
+	return differs, nil
+}
+
+// diff returns the unified diff of b1 and b2 by the diff command, as gofmt
+// does.
+func diff(file string, b1, b2 []byte) ([]byte, error) {
+	f1, err := writeTemp("carousel-fmt", b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+
+	f2, err := writeTemp("carousel-fmt", b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	out, err := exec.Command("diff", "-u", "--label", file+".orig", "--label", file, f1, f2).Output()
+	if len(out) > 0 {
+		// diff exits with 1 if files differ
+		return out, nil
+	}
+
+	return out, err
+}
+
+func writeTemp(prefix string, b []byte) (string, error) {
+	f, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
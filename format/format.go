@@ -0,0 +1,435 @@
+// Package format formats slides of the present format canonically, as
+// gofmt does for Go:
+//
+//   - trailing whitespace and carriage returns are removed
+//   - runs of blank lines become one, and leading and trailing ones are
+//     removed
+//   - headings of slides and sections have a blank line before them
+//   - indented blocks are indented by a tab
+//
+// Lines are read as the present parser reads them, so that the slides mean
+// the same after formatting; only empty authors, which present makes of
+// runs of blank lines after authors, go away. Comments, which are lines
+// starting with #, are kept where they are.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Indent is the indentation of indented blocks.
+const Indent = "\t"
+
+// kinds of lines, by which they are formatted
+type kind int
+
+const (
+	kindKeep    kind = iota // written as it is
+	kindBlank               // empty, or whitespace which means nothing
+	kindComment             // starts with #
+	kindText                // trailing whitespace is removed
+	kindHeading             // of a slide or a section
+	kindPre                 // in an indented block
+)
+
+type line struct {
+	text string
+	kind kind
+
+	block int // index of the first line of the indented block
+}
+
+// block is an indented block; its lines are kindPre.
+type block struct {
+	indent    string
+	last      int  // index of the last line which is not empty
+	canonical bool // whether it can be indented by Indent
+}
+
+// Source formats slides of the present format. An error is returned if the
+// slides can't be parsed.
+func Source(src []byte) ([]byte, error) {
+	if bytes.HasPrefix(src, []byte{0xff, 0xfe}) || bytes.HasPrefix(src, []byte{0xfe, 0xff}) {
+		return nil, errors.New("slides in UTF-16 can't be formatted")
+	}
+
+	p := newParser(src)
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+
+	return p.write(), nil
+}
+
+// parser walks lines as present.Parse does, deciding their kinds.
+type parser struct {
+	lines  []line
+	n      int // index of the line to be read next
+	blocks map[int]*block
+}
+
+func newParser(src []byte) *parser {
+	text := strings.Replace(string(src), "\r\n", "\n", -1)
+	text = strings.TrimSuffix(text, "\n")
+
+	p := &parser{
+		blocks: make(map[int]*block),
+	}
+	if text == "" {
+		return p
+	}
+
+	for _, s := range strings.Split(text, "\n") {
+		p.lines = append(p.lines, line{text: s})
+	}
+
+	return p
+}
+
+// next returns the next line which is not a comment.
+func (p *parser) next() (*line, bool) {
+	for {
+		current := p.n
+		p.n++
+		if current >= len(p.lines) {
+			return nil, false
+		}
+
+		l := &p.lines[current]
+		if !strings.HasPrefix(l.text, "#") {
+			return l, true
+		}
+		l.kind = kindComment
+	}
+}
+
+func (p *parser) back() {
+	p.n--
+}
+
+// nextNonEmpty returns the next line which is neither empty nor a comment,
+// marking empty lines skipped as blank.
+func (p *parser) nextNonEmpty() (*line, bool) {
+	for {
+		l, ok := p.next()
+		if !ok {
+			return nil, false
+		}
+		if l.text != "" {
+			return l, true
+		}
+		l.kind = kindBlank
+	}
+}
+
+func (p *parser) parse() error {
+	if err := p.parseHeader(); err != nil {
+		return err
+	}
+
+	if err := p.parseAuthors(); err != nil {
+		return err
+	}
+
+	// lines after the sections are ignored by present, and kept
+	p.parseSections("")
+
+	p.checkBlocks()
+	return nil
+}
+
+func (p *parser) parseHeader() error {
+	title, ok := p.nextNonEmpty()
+	if !ok {
+		return errors.New("unexpected EOF; expected title")
+	}
+	markText(title)
+
+	subtitle := false
+	for {
+		l, ok := p.next()
+		if !ok {
+			return errors.New("unexpected EOF")
+		}
+		if l.text == "" {
+			l.kind = kindBlank
+			return nil
+		}
+
+		switch {
+		case strings.HasPrefix(l.text, "Tags:"), isTime(l.text):
+		case !subtitle:
+			subtitle = true
+		default:
+			return fmt.Errorf("unexpected header line: %q", l.text)
+		}
+		markText(l)
+	}
+}
+
+// isTime tells whether the header line is the time, in the layouts of
+// present.
+func isTime(text string) bool {
+	for _, layout := range []string{"15:04 2 Jan 2006", "2 Jan 2006"} {
+		if _, err := time.Parse(layout, text); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *parser) parseAuthors() error {
+	if _, ok := p.nextNonEmpty(); !ok {
+		return errors.New("unexpected EOF")
+	}
+	p.back()
+
+	for {
+		l, ok := p.next()
+		if !ok {
+			return errors.New("unexpected EOF")
+		}
+
+		if strings.HasPrefix(l.text, "* ") {
+			p.back()
+			return nil
+		}
+
+		if l.text == "" {
+			l.kind = kindBlank
+			continue
+		}
+		markText(l)
+	}
+}
+
+var isHeading = regexp.MustCompile(`^\*+ `)
+
+func lesserHeading(text, prefix string) bool {
+	return isHeading.MatchString(text) && !strings.HasPrefix(text, prefix+"*")
+}
+
+// parseSections reads sections whose headings start with one more "*" than
+// parent, the prefix of headings of the parent section.
+func (p *parser) parseSections(parent string) {
+	prefix := parent + "*"
+
+	for {
+		l, ok := p.nextNonEmpty()
+		if !ok {
+			return
+		}
+		if !strings.HasPrefix(l.text, prefix+" ") {
+			p.back()
+			return
+		}
+		l.kind = kindHeading
+
+		l, ok = p.nextNonEmpty()
+		for ok && !lesserHeading(l.text, prefix) {
+			r, _ := utf8.DecodeRuneInString(l.text)
+			switch {
+			case unicode.IsSpace(r):
+				i := strings.IndexFunc(l.text, func(r rune) bool {
+					return !unicode.IsSpace(r)
+				})
+				if i < 0 {
+					// skipped; see checkBlocks for ones ending blocks
+					l.kind = kindBlank
+					break
+				}
+				p.back()
+				p.parsePre(l.text[:i])
+
+			case strings.HasPrefix(l.text, "- "):
+				for ok && strings.HasPrefix(l.text, "- ") {
+					markText(l)
+					l, ok = p.next()
+				}
+				p.back()
+
+			case strings.HasPrefix(l.text, prefix+"* "):
+				p.back()
+				p.parseSections(prefix)
+
+			case strings.HasPrefix(l.text, "."):
+				markText(l)
+
+			default:
+				// a paragraph goes on until an empty line or a command;
+				// present skips the line ending it, even a command
+				for ok && strings.TrimSpace(l.text) != "" {
+					if l.text[0] == '.' {
+						break
+					}
+					markText(l)
+					l, ok = p.next()
+				}
+				if ok && strings.TrimSpace(l.text) == "" {
+					l.kind = kindBlank
+				}
+			}
+
+			l, ok = p.nextNonEmpty()
+		}
+
+		if ok && isHeading.MatchString(l.text) {
+			p.back()
+		}
+	}
+}
+
+// parsePre reads an indented block starting at the next line.
+func (p *parser) parsePre(indent string) {
+	start := p.n
+	b := &block{indent: indent, last: start}
+	p.blocks[start] = b
+
+	l, ok := p.next()
+	for ok && (strings.HasPrefix(l.text, indent) || l.text == "") {
+		l.kind = kindPre
+		l.block = start
+		if strings.TrimSpace(l.text) != "" {
+			b.last = p.n - 1
+		}
+		l, ok = p.next()
+	}
+	p.back()
+}
+
+// checkBlocks decides which indented blocks can be indented by Indent. A
+// block next to lines starting with whitespace is left as it is, as they
+// might become a part of it. Whitespace ending a block is kept as well, for
+// an empty line would not end it.
+func (p *parser) checkBlocks() {
+	for start, b := range p.blocks {
+		b.canonical = true
+
+		for i := b.last + 1; i < len(p.lines); i++ {
+			l := &p.lines[i]
+			if l.kind == kindComment || l.text == "" || l.kind == kindPre && l.block == start {
+				continue
+			}
+			if startsWithSpace(l.text) {
+				b.canonical = false
+				if strings.TrimSpace(l.text) == "" {
+					l.kind = kindKeep
+				}
+			}
+			break
+		}
+	}
+
+	// lines written as blank don't matter before blocks
+	for start, b := range p.blocks {
+		for i := start - 1; i >= 0; i-- {
+			l := &p.lines[i]
+			if l.kind == kindComment || l.kind == kindBlank || l.text == "" {
+				continue
+			}
+			if startsWithSpace(l.text) {
+				b.canonical = false
+			}
+			break
+		}
+	}
+}
+
+func startsWithSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+// markText marks the line whose trailing whitespace can be removed.
+func markText(l *line) {
+	if strings.TrimSpace(l.text) != "" {
+		l.kind = kindText
+	}
+}
+
+// trimLine removes trailing whitespace of the line, but a space after the
+// marker of an empty list item or heading is left, which makes it one.
+func trimLine(s string) string {
+	t := trimRight(s)
+	if t != s && t != "" && (t == "-" || strings.Trim(t, "*") == "") {
+		return t + " "
+	}
+
+	return t
+}
+
+func trimRight(s string) string {
+	return strings.TrimRight(s, " \t\r")
+}
+
+func (p *parser) write() []byte {
+	var out []string
+
+	// blank lines are added only after others, so that runs of them, and
+	// ones at the start, go away
+	blank := func() {
+		if len(out) > 0 && out[len(out)-1] != "" {
+			out = append(out, "")
+		}
+	}
+
+	for i, l := range p.lines {
+		switch l.kind {
+		case kindBlank:
+			blank()
+
+		case kindComment:
+			out = append(out, trimRight(l.text))
+
+		case kindText:
+			out = append(out, trimLine(l.text))
+
+		case kindHeading:
+			// before the comments right above the heading, if any
+			j := len(out)
+			for j > 0 && strings.HasPrefix(out[j-1], "#") {
+				j--
+			}
+			if j > 0 && out[j-1] != "" {
+				out = append(out[:j], append([]string{""}, out[j:]...)...)
+			}
+
+			out = append(out, trimLine(l.text))
+
+		case kindPre:
+			b := p.blocks[l.block]
+			switch {
+			case i > b.last:
+				// empty lines after the block are not a part of it
+				blank()
+			case strings.TrimSpace(l.text) == "":
+				out = append(out, "")
+			case b.canonical:
+				out = append(out, Indent+trimRight(l.text[len(b.indent):]))
+			default:
+				out = append(out, trimRight(l.text))
+			}
+
+		default:
+			out = append(out, l.text)
+		}
+	}
+
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(out, "\n") + "\n")
+}
@@ -0,0 +1,202 @@
+package format
+
+import (
+	"bytes"
+	"code.google.com/p/go.tools/present"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// parse parses the slides as carousel does, reading files of directives
+// in dir.
+func parse(t *testing.T, src []byte, dir string) *present.Doc {
+	ctx := present.Context{ReadFile: func(name string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(dir, name))
+	}}
+
+	doc, err := ctx.Parse(bytes.NewReader(src), "slides", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// formatting removes empty authors, which runs of blank lines make
+	var authors []present.Author
+	for _, a := range doc.Authors {
+		if !emptyAuthor(a) {
+			authors = append(authors, a)
+		}
+	}
+	doc.Authors = authors
+
+	// trailing whitespace, which formatting removes, is not rendered
+	doc.Title, doc.Subtitle = trimRight(doc.Title), trimRight(doc.Subtitle)
+	for _, a := range doc.Authors {
+		trimElems(a.Elem)
+	}
+	for i := range doc.Sections {
+		trimSection(&doc.Sections[i])
+	}
+
+	return doc
+}
+
+func trimSection(s *present.Section) {
+	s.Title = trimRight(s.Title)
+	trimElems(s.Elem)
+}
+
+func trimElems(elems []present.Elem) {
+	for i, e := range elems {
+		switch t := e.(type) {
+		case present.Section:
+			trimSection(&t)
+			elems[i] = t
+		case present.Text:
+			if !t.Pre {
+				for j := range t.Lines {
+					t.Lines[j] = trimRight(t.Lines[j])
+				}
+			}
+		case present.List:
+			for j := range t.Bullet {
+				t.Bullet[j] = trimRight(t.Bullet[j])
+			}
+		}
+	}
+}
+
+func emptyAuthor(a present.Author) bool {
+	for _, e := range a.Elem {
+		text, ok := e.(present.Text)
+		if !ok {
+			return false
+		}
+		for _, l := range text.Lines {
+			if l != "" {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// checkFormat formats the slides, and checks that they mean the same and
+// that formatting them again changes nothing.
+func checkFormat(t *testing.T, name string, src []byte, dir string) []byte {
+	out, err := Source(src)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	if want, got := parse(t, src, dir), parse(t, out, dir); !reflect.DeepEqual(want, got) {
+		t.Errorf("%s: formatting changes the slides:\n%s", name, out)
+	}
+
+	again, err := Source(out)
+	if err != nil {
+		t.Fatalf("%s: formatted again: %v", name, err)
+	}
+	if !bytes.Equal(again, out) {
+		t.Errorf("%s: formatting is not idempotent:\n%s\nformatted again:\n%s", name, out, again)
+	}
+
+	return out
+}
+
+func TestSamples(t *testing.T) {
+	files, err := filepath.Glob("../samples/*.slide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no samples")
+	}
+
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkFormat(t, file, src, filepath.Dir(file))
+	}
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "canonical",
+			src:  "Title\n\nAuthor\n\n* Slide\n\nText\n",
+			want: "Title\n\nAuthor\n\n* Slide\n\nText\n",
+		},
+		{
+			name: "trailing whitespace and carriage returns",
+			src:  "Title  \r\nSubtitle\t\r\n\r\nAuthor \r\n\r\n* Slide \r\n\r\n- item  \r\n",
+			want: "Title\nSubtitle\n\nAuthor\n\n* Slide\n\n- item\n",
+		},
+		{
+			name: "runs of blank lines",
+			src:  "\n\nTitle\n\nAuthor\n\n\n\n* Slide\n\n\n\nText\n\n\n",
+			want: "Title\n\nAuthor\n\n* Slide\n\nText\n",
+		},
+		{
+			name: "heading without a blank line",
+			src:  "Title\n\n* Slide\n- item\n* Next\nText\n",
+			want: "Title\n\n* Slide\n- item\n\n* Next\nText\n",
+		},
+		{
+			name: "comments above a heading",
+			src:  "Title\n\n* Slide\n\n- item\n# about the next\n* Next\n",
+			want: "Title\n\n* Slide\n\n- item\n\n# about the next\n* Next\n",
+		},
+		{
+			name: "indented by spaces",
+			src:  "Title\n\n* Slide\n\n    func main() {\n        fmt.Println()\n    }\n",
+			want: "Title\n\n* Slide\n\n\tfunc main() {\n\t    fmt.Println()\n\t}\n",
+		},
+		{
+			name: "empty list item",
+			src:  "Title\n\n* Slide\n\n- \n- item\n",
+			want: "Title\n\n* Slide\n\n- \n- item\n",
+		},
+		{
+			// present reads them as lines of the paragraph
+			name: "headings in a paragraph",
+			src:  "Title\n\n* Slide\n\nText\n# comment\n** Section\n",
+			want: "Title\n\n* Slide\n\nText\n# comment\n** Section\n",
+		},
+		{
+			name: "sections",
+			src:  "Title\n\n* Slide\n** Section\n- item\n*** Sub\nMore\n",
+			want: "Title\n\n* Slide\n\n** Section\n- item\n\n*** Sub\nMore\n",
+		},
+	}
+
+	for _, test := range tests {
+		out := checkFormat(t, test.name, []byte(test.src), ".")
+		if string(out) != test.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", test.name, out, test.want)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"\n\n",
+		"Title",
+		"Title\nSubtitle\nunexpected\n\n* Slide\n",
+		"\xff\xfeT\x00",
+	} {
+		if _, err := Source([]byte(src)); err == nil {
+			t.Errorf("%q: formatted without an error", src)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package highlight
+
+import (
+	"go/scanner"
+	"go/token"
+)
+
+func init() {
+	Register("go", lexGo, "golang")
+}
+
+var goBuiltins = map[string]bool{
+	"append": true, "cap": true, "close": true, "complex": true, "copy": true,
+	"delete": true, "imag": true, "len": true, "make": true, "new": true,
+	"panic": true, "print": true, "println": true, "real": true, "recover": true,
+
+	"bool": true, "byte": true, "complex64": true, "complex128": true,
+	"error": true, "float32": true, "float64": true, "int": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "rune": true, "string": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"uintptr": true,
+
+	"true": true, "false": true, "iota": true, "nil": true,
+}
+
+// lexGo splits Go code by go/scanner. Snippets need not be complete
+// programs; errors of scanning are left as plain text.
+func lexGo(src string) []Token {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+
+	var tokens []Token
+	last := 0
+
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+
+		// automatically inserted semicolons
+		if tok == token.SEMICOLON && lit != ";" {
+			continue
+		}
+
+		class := ""
+		switch {
+		case tok.IsKeyword():
+			class = Keyword
+		case tok == token.STRING || tok == token.CHAR:
+			class = String
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			class = Number
+		case tok == token.COMMENT:
+			class = Comment
+		case tok == token.IDENT && goBuiltins[lit]:
+			class = Builtin
+		}
+
+		if class == "" {
+			continue
+		}
+
+		start := file.Offset(pos)
+		end := start + len(lit)
+		if start < last || end > len(src) {
+			continue
+		}
+
+		if start > last {
+			tokens = append(tokens, Token{"", src[last:start]})
+		}
+		tokens = append(tokens, Token{class, src[start:end]})
+		last = end
+	}
+
+	if last < len(src) {
+		tokens = append(tokens, Token{"", src[last:]})
+	}
+
+	return tokens
+}
@@ -0,0 +1,121 @@
+package highlight
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Classes of tokens. A token is rendered in a span of "tok-<class>", so
+// style sheets of themes can restyle them.
+const (
+	Keyword  = "keyword"
+	String   = "string"
+	Number   = "number"
+	Comment  = "comment"
+	Builtin  = "builtin"
+	Variable = "variable"
+	Key      = "key"
+)
+
+// Token is a piece of source code. Class is empty for plain text.
+type Token struct {
+	Class string
+	Text  string
+}
+
+// Lexer splits source code into tokens; texts of the tokens concatenated
+// must be the source.
+type Lexer func(src string) []Token
+
+var (
+	mu      sync.RWMutex
+	lexers  = make(map[string]Lexer)  // by language
+	aliases = make(map[string]string) // alias or file extension to language
+)
+
+// Register adds the lexer of the language. Aliases are other names of the
+// language and file extensions without the dot.
+func Register(lang string, lex Lexer, alias ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lexers[lang] = lex
+	for _, a := range alias {
+		aliases[a] = lang
+	}
+}
+
+// Lookup returns the lexer of the language given by its name, an alias or
+// a file extension with or without the dot.
+func Lookup(lang string) (Lexer, bool) {
+	lang = strings.ToLower(strings.TrimPrefix(lang, "."))
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if l, ok := aliases[lang]; ok {
+		lang = l
+	}
+
+	lex, ok := lexers[lang]
+	return lex, ok
+}
+
+// Languages returns names of the registered languages.
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var langs []string
+	for lang := range lexers {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	return langs
+}
+
+// Lines splits the source into lines of tokens, so that no token spans
+// lines.
+func Lines(lex Lexer, src string) [][]Token {
+	lines := [][]Token{nil}
+
+	for _, t := range lex(src) {
+		parts := strings.Split(t.Text, "\n")
+		for i, p := range parts {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if p != "" {
+				n := len(lines) - 1
+				lines[n] = append(lines[n], Token{t.Class, p})
+			}
+		}
+	}
+
+	return lines
+}
+
+// HTML renders the tokens escaped.
+func HTML(tokens []Token) template.HTML {
+	var b bytes.Buffer
+
+	for _, t := range tokens {
+		if t.Class == "" {
+			b.WriteString(html.EscapeString(t.Text))
+			continue
+		}
+
+		b.WriteString(`<span class="tok-`)
+		b.WriteString(t.Class)
+		b.WriteString(`">`)
+		b.WriteString(html.EscapeString(t.Text))
+		b.WriteString(`</span>`)
+	}
+
+	return template.HTML(b.String())
+}
@@ -0,0 +1,96 @@
+package highlight
+
+func init() {
+	Register("python", python.lex, "py")
+	Register("shell", shell.lex, "sh", "bash", "zsh", "console")
+	Register("json", json.lex)
+	Register("yaml", yaml.lex, "yml")
+	Register("sql", sql.lex)
+}
+
+var python = &regexpLexer{
+	rules: []rule{
+		newRule(Comment, `#.*`),
+		newRule(String, `(?s)[rRbBuUfF]{0,2}""".*?"""`),
+		newRule(String, `(?s)[rRbBuUfF]{0,2}'''.*?'''`),
+		newRule(String, `[rRbBuUfF]{0,2}"(?:[^"\\\n]|\\.)*"`),
+		newRule(String, `[rRbBuUfF]{0,2}'(?:[^'\\\n]|\\.)*'`),
+		newRule(Builtin, `@[A-Za-z_][A-Za-z0-9_.]*`),
+		newRule(Number, `(?:0[xXoObB][0-9a-fA-F_]+|[0-9][0-9_]*(?:\.[0-9_]*)?(?:[eE][+-]?[0-9]+)?j?)`),
+	},
+	words: wordClasses(map[string]string{
+		Keyword: `and as assert async await break class continue def del elif else
+			except finally for from global if import in is lambda nonlocal not or
+			pass raise return try while with yield True False None`,
+		Builtin: `abs all any bool bytes dict dir enumerate filter float format
+			getattr hasattr int isinstance len list map max min object open print
+			range repr reversed set sorted str sum super tuple type zip self`,
+	}),
+}
+
+var shell = &regexpLexer{
+	rules: []rule{
+		newRule(Comment, `#.*`),
+		newRule(String, `"(?:[^"\\]|\\.)*"`),
+		newRule(String, `'[^']*'`),
+		newRule(Variable, `\$(?:\{[^}\n]*\}|[A-Za-z_][A-Za-z0-9_]*|[0-9@#?$!*-])`),
+		newRule(Number, `[0-9]+\b`),
+		// words with dashes such as command options are not keywords
+		newRule("", `[A-Za-z0-9_]*-[A-Za-z0-9_-]*`),
+	},
+	words: wordClasses(map[string]string{
+		Keyword: `if then else elif fi for while until do done case esac in
+			function return select break continue`,
+		Builtin: `cd echo eval exec exit export local read set shift source test
+			trap unset alias printf`,
+	}),
+}
+
+var json = &regexpLexer{
+	rules: []rule{
+		newRule(Key, `("(?:[^"\\]|\\.)*")\s*:`),
+		newRule(String, `"(?:[^"\\]|\\.)*"`),
+		newRule(Number, `-?[0-9]+(?:\.[0-9]+)?(?:[eE][+-]?[0-9]+)?`),
+	},
+	words: wordClasses(map[string]string{
+		Keyword: `true false null`,
+	}),
+}
+
+var yaml = &regexpLexer{
+	rules: []rule{
+		newRule(Comment, `#.*`),
+		newRule(Key, `([A-Za-z_][A-Za-z0-9_.-]*)\s*:(?:[ \t\n]|$)`),
+		newRule(Key, `("(?:[^"\\]|\\.)*")\s*:(?:[ \t\n]|$)`),
+		newRule(String, `"(?:[^"\\]|\\.)*"`),
+		newRule(String, `'(?:[^']|'')*'`),
+		newRule(Keyword, `---|\.\.\.`),
+		newRule(Variable, `[&*][A-Za-z0-9_-]+`),
+		newRule(Number, `-?[0-9]+(?:\.[0-9]+)?\b`),
+	},
+	words: wordClasses(map[string]string{
+		Keyword: `true false null yes no on off True False Null`,
+	}),
+}
+
+var sql = &regexpLexer{
+	rules: []rule{
+		newRule(Comment, `--.*`),
+		newRule(Comment, `(?s)/\*.*?\*/`),
+		newRule(String, `'(?:[^']|'')*'`),
+		newRule(String, `"(?:[^"]|"")*"`),
+		newRule(Number, `[0-9]+(?:\.[0-9]+)?`),
+	},
+	words: wordClasses(map[string]string{
+		Keyword: `select from where and or not in is null as join inner left right
+			outer full cross on group by order having limit offset insert into
+			values update set delete create table index view drop alter add
+			column primary key foreign references unique default distinct union
+			all case when then else end exists between like asc desc begin
+			commit rollback transaction with returning`,
+		Builtin: `count sum avg min max coalesce cast now lower upper length
+			int integer bigint smallint text varchar char boolean date time
+			timestamp numeric decimal real serial`,
+	}),
+	ignoreCase: true,
+}
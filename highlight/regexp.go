@@ -0,0 +1,100 @@
+package highlight
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// rule makes a token of the class from the text matched at the current
+// position. If the expression has a group, only the group is the token.
+type rule struct {
+	class string
+	re    *regexp.Regexp
+}
+
+func newRule(class, expr string) rule {
+	return rule{class, regexp.MustCompile(`^(?:` + expr + `)`)}
+}
+
+var wordRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+// regexpLexer is a lexer by regular expressions for languages whose
+// structure is simple enough to be highlighted without a parser.
+type regexpLexer struct {
+	rules      []rule
+	words      map[string]string // class of keywords and builtins
+	ignoreCase bool
+}
+
+func (l *regexpLexer) lex(src string) []Token {
+	var tokens []Token
+	plain := 0 // start of the pending plain text
+
+	flush := func(i int) {
+		if plain < i {
+			tokens = append(tokens, Token{"", src[plain:i]})
+		}
+	}
+
+	for i := 0; i < len(src); {
+		class, n := l.match(src[i:])
+
+		if n == 0 {
+			_, size := utf8.DecodeRuneInString(src[i:])
+			i += size
+			continue
+		}
+
+		if class != "" {
+			flush(i)
+			tokens = append(tokens, Token{class, src[i : i+n]})
+			plain = i + n
+		}
+		i += n
+	}
+	flush(len(src))
+
+	return tokens
+}
+
+// match returns the class and the length of the token at the start of s.
+// Words which are neither keywords nor builtins have no class.
+func (l *regexpLexer) match(s string) (string, int) {
+	for _, r := range l.rules {
+		loc := r.re.FindStringSubmatchIndex(s)
+		if loc == nil {
+			continue
+		}
+
+		if len(loc) >= 4 && loc[2] == 0 {
+			return r.class, loc[3]
+		}
+		if loc[1] > 0 {
+			return r.class, loc[1]
+		}
+	}
+
+	if loc := wordRE.FindStringIndex(s); loc != nil {
+		w := s[:loc[1]]
+		if l.ignoreCase {
+			w = strings.ToLower(w)
+		}
+		return l.words[w], loc[1]
+	}
+
+	return "", 0
+}
+
+// wordClasses returns a table of words in the classes.
+func wordClasses(classes map[string]string) map[string]string {
+	words := make(map[string]string)
+
+	for class, list := range classes {
+		for _, w := range strings.Fields(list) {
+			words[w] = class
+		}
+	}
+
+	return words
+}
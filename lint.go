@@ -0,0 +1,103 @@
+package main
+
+import (
+	"carousel/lint"
+	"carousel/playground"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+var lintCommand = &command{
+	name:  "lint",
+	args:  "filepath|directory...",
+	short: "check slides without serving them",
+	long: `
+Lint checks slides of files, and slides of either format in directories,
+for missing files, patterns of .code matching nothing, .play snippets of Go
+which don't build, empty or too long slides and images without alt text.
+Binaries of snippets built are kept in playCache of the config, so that
+serve runs them at once. Problems are printed one per line, or as a JSON
+array by -json.
+
+Lint exits with 3 if any problem is found, and with 1 if slides can't be
+checked at all.`,
+	flags: lintFlags,
+	run:   runLint,
+}
+
+var (
+	lintJSON  bool
+	lintBuild bool
+)
+
+func lintFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&lintJSON, "json", false, "print problems in JSON")
+	fs.BoolVar(&lintBuild, "build", true, "build .play snippets of Go")
+
+	inputFlags(fs)
+	logFlags(fs)
+}
+
+func runLint(cmd *command, fs *flag.FlagSet) int {
+	if fs.NArg() < 1 {
+		return cmd.usageError("no slides given")
+	}
+
+	if err := loadConfig(fs, fs.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	cleanup := setupLogging()
+	defer cleanup()
+
+	if err := checkInput(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	files, err := deckFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	var cache *playground.Cache
+	if lintBuild {
+		cache = playground.NewCache(cfg.PlayCache, playLimits(cfg.PlayLimits))
+	}
+
+	problems := []lint.Problem{}
+	failed := false
+
+	for _, file := range files {
+		p, err := lint.Lint(file, formatOf(file, cfg.Format), cfg.Encoding, cache)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to lint '%s': %v\n", file, err)
+			failed = true
+			continue
+		}
+
+		problems = append(problems, p...)
+	}
+
+	if lintJSON {
+		b, _ := json.MarshalIndent(problems, "", "  ")
+		fmt.Println(string(b))
+	} else {
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+	}
+
+	switch {
+	case failed:
+		return exitFailure
+	case len(problems) > 0:
+		return exitProblems
+	}
+
+	return exitOK
+}
@@ -0,0 +1,157 @@
+package lint
+
+import (
+	"carousel/renderer"
+	"code.google.com/p/go.tools/present"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	addrPatternRE = regexp.MustCompile(`/(?:[^/\\]|\\.)+/`)
+	altRE         = regexp.MustCompile(`^#alt:\s*\S`)
+	mdImageRE     = regexp.MustCompile(`!\[([^\]]*)\]\(\s*([^\s)]+)`)
+)
+
+// checkDirectives checks files referred by directives of the present
+// format line by line, so that every missing file is reported while
+// parsing stops at the first one.
+func (l *linter) checkDirectives() {
+	for i, text := range l.lines {
+		line := i + 1
+		text = strings.TrimSpace(text)
+
+		f := strings.Fields(text)
+		if len(f) < 2 {
+			continue
+		}
+
+		switch f[0] {
+		case ".code", ".play":
+			l.checkCode(line, text)
+
+		case ".image", ".iframe", ".html":
+			if !renderer.IsLocalURL(f[1]) {
+				break
+			}
+
+			if _, err := os.Stat(l.path(f[1])); err != nil {
+				l.report(line, CheckMissingFile, "%s: %s not found", f[0], f[1])
+				break
+			}
+
+			if f[0] == ".image" && (i+1 >= len(l.lines) || !altRE.MatchString(strings.TrimSpace(l.lines[i+1]))) {
+				l.report(line, CheckNoAlt, "image %s has no alternative text; add '#alt: text' on the next line", f[1])
+			}
+		}
+	}
+}
+
+func (l *linter) checkCode(line int, cmd string) {
+	highlight := ""
+	if hl := renderer.HighlightRE.FindStringSubmatchIndex(cmd); len(hl) == 4 {
+		if hl[2] >= 0 {
+			highlight = cmd[hl[2]:hl[3]]
+		}
+		cmd = cmd[:hl[0]]
+	}
+
+	args := renderer.CodeRE.FindStringSubmatch(cmd)
+	if len(args) != 5 {
+		// parsing reports it
+		return
+	}
+	command, file, addr := args[1], args[3], args[4]
+
+	b, err := ioutil.ReadFile(l.path(file))
+	if err != nil {
+		l.report(line, CheckMissingFile, ".%s: %s not found", command, file)
+		return
+	}
+
+	lines := strings.Split(string(b), "\n")
+
+	for _, pattern := range addrPatternRE.FindAllString(addr, -1) {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			// parsing reports it
+			continue
+		}
+
+		if !anyLine(lines, re.MatchString) {
+			l.report(line, CheckNoMatch, "address %s matches nothing in %s", pattern, file)
+		}
+	}
+
+	if highlight != "" {
+		marked := anyLine(lines, func(s string) bool {
+			m := renderer.HLCommentRE.FindStringSubmatch(s)
+			return m != nil && m[2] == highlight
+		})
+
+		if !marked {
+			l.report(line, CheckNoMatch, "HL%s marks no line in %s", highlight, file)
+		}
+	}
+
+	if command == "play" {
+		l.plays = append(l.plays, line)
+	}
+}
+
+// checkMarkdownImages checks images of Markdown slides.
+func (l *linter) checkMarkdownImages() {
+	for i, text := range l.lines {
+		for _, m := range mdImageRE.FindAllStringSubmatch(text, -1) {
+			alt, u := m[1], m[2]
+
+			if renderer.IsLocalURL(u) {
+				if _, err := os.Stat(l.path(u)); err != nil {
+					l.report(i+1, CheckMissingFile, "image %s not found", u)
+					continue
+				}
+			}
+
+			if strings.TrimSpace(alt) == "" {
+				l.report(i+1, CheckNoAlt, "image %s has no alternative text", u)
+			}
+		}
+	}
+}
+
+func (l *linter) path(name string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(name))
+}
+
+// checkBuilds builds .play snippets of Go as the playground runs them,
+// which keeps their binaries in the cache for the talk. Snippets are bound
+// to lines of .play in order.
+func (l *linter) checkBuilds(doc *present.Doc) {
+	for i, s := range renderer.PlaySnippets(doc) {
+		if s.Ext != ".go" {
+			continue
+		}
+
+		line := 0
+		if i < len(l.plays) {
+			line = l.plays[i]
+		}
+
+		if err := l.cache.Build(s.Body, false); err != nil {
+			l.report(line, CheckBuild, "%s doesn't build: %v", s.File, err)
+		}
+	}
+}
+
+func anyLine(lines []string, match func(string) bool) bool {
+	for _, s := range lines {
+		if match(s) {
+			return true
+		}
+	}
+
+	return false
+}
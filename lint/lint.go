@@ -0,0 +1,132 @@
+package lint
+
+import (
+	"carousel/playground"
+	"carousel/renderer"
+	"code.google.com/p/go.tools/present"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Checks of lint.
+const (
+	CheckParse       = "parse"
+	CheckMissingFile = "missing-file"
+	CheckNoMatch     = "no-match"
+	CheckBuild       = "build"
+	CheckTooLong     = "too-long"
+	CheckEmptySlide  = "empty-slide"
+	CheckNoAlt       = "no-alt"
+)
+
+// Problem is a flaw found in slides.
+type Problem struct {
+	File    string
+	Line    int `json:",omitempty"` // zero if not bound to a line
+	Check   string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s [%s]", p.File, p.Message, p.Check)
+	}
+
+	return fmt.Sprintf("%s:%d: %s [%s]", p.File, p.Line, p.Message, p.Check)
+}
+
+// Lint checks the slides without serving them. format and encoding are
+// the same as those of renderer. .play snippets of Go are built by cache,
+// which takes a while, unless it is nil. The error is returned only if the
+// slides can't be read at all.
+func Lint(filename, format, encoding string, cache *playground.Cache) ([]Problem, error) {
+	doc, b, err := renderer.Parse(filename, format, encoding)
+	if b == nil && err != nil {
+		return nil, err
+	}
+
+	return check(filename, format, doc, b, err, cache), nil
+}
+
+// Source checks the decoded slides of filename given in b, such as ones
+// being edited and not saved yet. Files of directives are read next to
+// filename.
+func Source(filename, format string, b []byte, cache *playground.Cache) []Problem {
+	doc, err := renderer.ParseSource(b, filename, format)
+	return check(filename, format, doc, b, err, cache)
+}
+
+// check checks the slides parsed into doc, or failed to be parsed by err.
+func check(filename, format string, doc *present.Doc, b []byte, err error, cache *playground.Cache) []Problem {
+	l := &linter{
+		filename: filename,
+		dir:      filepath.Dir(filename),
+		lines:    strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"),
+		cache:    cache,
+	}
+
+	if format == renderer.FormatMarkdown {
+		l.checkMarkdownImages()
+	} else {
+		l.checkDirectives()
+	}
+
+	if err != nil {
+		l.parseFailed(err)
+	} else {
+		l.checkSlides(doc, format)
+
+		if cache != nil {
+			l.checkBuilds(doc)
+		}
+	}
+
+	sort.Stable(byLine(l.problems))
+
+	return l.problems
+}
+
+type linter struct {
+	filename string
+	dir      string
+	lines    []string
+	cache    *playground.Cache
+	plays    []int // lines of .play
+
+	problems []Problem
+}
+
+func (l *linter) report(line int, check, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{
+		File:    l.filename,
+		Line:    line,
+		Check:   check,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// parseFailed reports the error of parsing, unless it is on a line already
+// reported, such as a missing file of .code.
+func (l *linter) parseFailed(err error) {
+	line := 0
+	if pe, ok := err.(*renderer.ParseError); ok {
+		line = pe.Line
+		err = fmt.Errorf("%s", pe.Message)
+	}
+
+	for _, p := range l.problems {
+		if line != 0 && p.Line == line {
+			return
+		}
+	}
+
+	l.report(line, CheckParse, "%v; checks of slides are skipped", err)
+}
+
+type byLine []Problem
+
+func (p byLine) Len() int           { return len(p) }
+func (p byLine) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byLine) Less(i, j int) bool { return p[i].Line < p[j].Line }
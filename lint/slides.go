@@ -0,0 +1,122 @@
+package lint
+
+import (
+	"carousel/renderer"
+	"code.google.com/p/go.tools/present"
+	"strings"
+)
+
+// Rough metrics of the default style of slides in pixels, to tell slides
+// which overflow.
+const (
+	_SLIDE_HEIGHT     = 620 // 700 minus paddings
+	_TITLE_HEIGHT     = 56
+	_TEXT_LINE_HEIGHT = 36
+	_CODE_LINE_HEIGHT = 24
+	_BLOCK_MARGIN     = 20
+	_BULLET_MARGIN    = 13
+	_CHARS_PER_LINE   = 70 // of text before wrapping
+)
+
+// checkSlides checks the parsed slides.
+func (l *linter) checkSlides(doc *present.Doc, format string) {
+	starts := l.slideLines(format)
+
+	for i, s := range doc.Sections {
+		line := 0
+		if i < len(starts) {
+			line = starts[i]
+		}
+
+		if strings.TrimSpace(s.Title) == "" && len(s.Elem) == 0 {
+			l.report(line, CheckEmptySlide, "slide %d is empty", i+1)
+			continue
+		}
+
+		if h := sectionHeight(s); h > _SLIDE_HEIGHT {
+			l.report(line, CheckTooLong, "slide %q has too many lines to fit: about %d px of %d px", s.Title, h, _SLIDE_HEIGHT)
+		}
+	}
+}
+
+// slideLines returns line numbers where slides start.
+func (l *linter) slideLines(format string) (starts []int) {
+	fenced, title := false, false
+
+	for i, text := range l.lines {
+		if format != renderer.FormatMarkdown {
+			if strings.HasPrefix(text, "* ") || text == "*" {
+				starts = append(starts, i+1)
+			}
+			continue
+		}
+
+		if renderer.MarkdownFenceRE.MatchString(text) {
+			fenced = !fenced
+		}
+		if fenced {
+			continue
+		}
+
+		// headings of level 1 and 2 start slides
+		if m := renderer.MarkdownHeadingRE.FindStringSubmatch(text); m == nil || len(m[1]) > 2 {
+			continue
+		}
+
+		// the first heading is the title of the slides
+		if !title {
+			title = true
+			continue
+		}
+		starts = append(starts, i+1)
+	}
+
+	return
+}
+
+// sectionHeight estimates the height of the section rendered.
+func sectionHeight(s present.Section) (h int) {
+	if s.Title != "" {
+		h += _TITLE_HEIGHT
+	}
+
+	for _, e := range s.Elem {
+		switch t := e.(type) {
+		case present.Section:
+			h += sectionHeight(t)
+
+		case present.Text:
+			if t.Pre {
+				h += 2*_BLOCK_MARGIN + _CODE_LINE_HEIGHT*countLines(strings.Join(t.Lines, "\n"))
+			} else {
+				h += _BLOCK_MARGIN
+				for _, s := range t.Lines {
+					h += _TEXT_LINE_HEIGHT * wrappedLines(s)
+				}
+			}
+
+		case present.List:
+			h += _BLOCK_MARGIN
+			for _, s := range t.Bullet {
+				h += _TEXT_LINE_HEIGHT*wrappedLines(s) + _BULLET_MARGIN
+			}
+
+		case present.Code:
+			h += 2*_BLOCK_MARGIN + _CODE_LINE_HEIGHT*countLines(string(t.Raw))
+
+		case present.Image:
+			h += 2 * _BLOCK_MARGIN
+			h += t.Height
+		}
+	}
+
+	return
+}
+
+func countLines(s string) int {
+	return strings.Count(strings.TrimRight(s, "\n"), "\n") + 1
+}
+
+func wrappedLines(s string) int {
+	return (len([]rune(s)) + _CHARS_PER_LINE - 1) / _CHARS_PER_LINE
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"carousel/config"
+	"carousel/lsp"
+	"carousel/playground"
+	"flag"
+	"fmt"
+	"os"
+)
+
+var lspCommand = &command{
+	name:  "lsp",
+	args:  "",
+	short: "serve slides to editors by the language server protocol",
+	long: `
+Lsp serves slides being edited to editors by the language server protocol
+over the standard input and output: problems found by lint, completion of
+directives and paths of files, definitions of snippets of .code and .play,
+the outline of sections and previews of snippets on hover.
+
+Snippets of .play are built when slides are opened and saved, unless
+-build=false is given, and their binaries are kept in playCache of the
+config for serve. Logs go to the standard error if the log is the
+standard output, which carries the protocol.`,
+	flags: lspFlags,
+	run:   runLsp,
+}
+
+var lspBuild bool
+
+func lspFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&lspBuild, "build", true, "build .play snippets of Go when slides are saved")
+	fs.StringVar(&cfg.Format, "f", cfg.Format, "input format: auto (by file extension), present or markdown")
+	fs.StringVar(&configFile, "config", "", "config file used instead of the user config ("+config.UserFile()+")")
+
+	logFlags(fs)
+}
+
+func runLsp(cmd *command, fs *flag.FlagSet) int {
+	if fs.NArg() != 0 {
+		return cmd.usageError("no arguments are taken")
+	}
+
+	if err := loadConfig(fs, ""); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	if cfg.Log == "stdout" {
+		cfg.Log = "stderr"
+	}
+
+	cleanup := setupLogging()
+	defer cleanup()
+
+	if err := checkInput(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	var cache *playground.Cache
+	if lspBuild {
+		cache = playground.NewCache(cfg.PlayCache, playLimits(cfg.PlayLimits))
+	}
+
+	if err := lsp.NewServer(cfg.Format, cache).Serve(os.Stdin, os.Stdout); err != nil {
+		logger.Errorf("%v", err)
+		return exitFailure
+	}
+
+	return exitOK
+}
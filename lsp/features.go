@@ -0,0 +1,553 @@
+package lsp
+
+import (
+	"bytes"
+	"carousel/lint"
+	"carousel/playground"
+	"carousel/renderer"
+	"code.google.com/p/go.tools/present"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// directives of the present format, for completion and hover
+var directives = []struct {
+	name   string
+	syntax string
+	doc    string
+}{
+	{".code", ".code [-numbers] [-edit] file [address] [HLtag]",
+		"Shows the snippet of the file selected by the address, such as /start/,/end/. Lines ending with OMIT are left out, and ones ending with `// HLtag` are highlighted."},
+	{".play", ".play [-numbers] [-edit] file [address] [HLtag]",
+		"Shows the snippet as .code does, and lets it run."},
+	{".image", ".image file [height width]",
+		"Shows the image. Either of height and width may be _ to keep the aspect ratio."},
+	{".link", ".link url [label]",
+		"Links to the url, labeled by the url itself without a label."},
+	{".iframe", ".iframe url height width",
+		"Embeds the page of the url."},
+	{".html", ".html file",
+		"Includes the HTML of the file."},
+	{".caption", ".caption text",
+		"Shows the text as a caption, usually under an image."},
+}
+
+// directives whose second field is a file relative to the slides
+var fileDirectives = map[string]bool{
+	".code":   true,
+	".play":   true,
+	".image":  true,
+	".iframe": true,
+	".html":   true,
+}
+
+var (
+	// line numbers of snippets rendered by present
+	codeNumRE = regexp.MustCompile(`<span num="([0-9]+)">`)
+
+	directiveNameRE = regexp.MustCompile(`^\.[a-z]*$`)
+	fileArgRE       = regexp.MustCompile(`^(\.[a-z]+)\s+((?:-\S*\s+)*)(\S*)$`)
+
+	headingRE = regexp.MustCompile(`^(\*+) +(.*?)\s*$`)
+)
+
+// diagnose publishes problems of the document found by lint, in the
+// background, building .play snippets if build and the server has a cache.
+// They are dropped if the document has changed meanwhile.
+func (s *Server) diagnose(uri string, build bool) {
+	d := s.document(uri)
+	if d == nil {
+		return
+	}
+
+	var cache *playground.Cache
+	if build {
+		cache = s.cache
+	}
+
+	go func() {
+		problems := lint.Source(d.path, d.format, []byte(d.text), cache)
+
+		lines := d.lines()
+		diagnostics := []Diagnostic{}
+		for _, p := range problems {
+			diagnostics = append(diagnostics, diagnosticOf(p, lines))
+		}
+
+		if current := s.document(uri); current == nil || current.version != d.version || current.text != d.text {
+			return
+		}
+
+		s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         uri,
+			Version:     d.version,
+			Diagnostics: diagnostics,
+		})
+	}()
+}
+
+func diagnosticOf(p lint.Problem, lines []string) Diagnostic {
+	severity := SeverityWarning
+	switch p.Check {
+	case lint.CheckParse, lint.CheckMissingFile, lint.CheckBuild:
+		severity = SeverityError
+	}
+
+	line := 0
+	if p.Line > 0 && p.Line <= len(lines) {
+		line = p.Line - 1
+	}
+
+	return Diagnostic{
+		Range:    lineRange(lines, line),
+		Severity: severity,
+		Code:     p.Check,
+		Source:   "carousel",
+		Message:  p.Message,
+	}
+}
+
+// lineRange returns the range of the line without leading whitespace.
+func lineRange(lines []string, line int) Range {
+	text := ""
+	if line < len(lines) {
+		text = strings.TrimRight(lines[line], "\r")
+	}
+
+	start := len(text) - len(strings.TrimLeftFunc(text, unicode.IsSpace))
+
+	return Range{
+		Start: Position{line, utf16Len(text[:start])},
+		End:   Position{line, utf16Len(text)},
+	}
+}
+
+// lineAt returns the line at pos, and the part of it before pos.
+func lineAt(d *document, pos Position) (string, string, bool) {
+	lines := d.lines()
+	if pos.Line < 0 || pos.Line >= len(lines) {
+		return "", "", false
+	}
+
+	line := strings.TrimRight(lines[pos.Line], "\r")
+	return line, line[:byteOffset(line, pos.Character)], true
+}
+
+func (s *Server) completion(d *document, pos Position) []CompletionItem {
+	items := []CompletionItem{}
+	if d == nil || d.format != renderer.FormatPresent {
+		return items
+	}
+
+	_, prefix, ok := lineAt(d, pos)
+	if !ok {
+		return items
+	}
+
+	if directiveNameRE.MatchString(prefix) {
+		edit := Range{Start: Position{pos.Line, 0}, End: pos}
+
+		for _, dir := range directives {
+			if !strings.HasPrefix(dir.name, prefix) {
+				continue
+			}
+
+			items = append(items, CompletionItem{
+				Label:         dir.name,
+				Kind:          CompletionKindKeyword,
+				Detail:        dir.syntax,
+				Documentation: &MarkupContent{"markdown", dir.doc},
+				TextEdit:      &TextEdit{edit, dir.name + " "},
+			})
+		}
+
+		return items
+	}
+
+	m := fileArgRE.FindStringSubmatch(prefix)
+	if m == nil || !fileDirectives[m[1]] {
+		return items
+	}
+	name, flags, arg := m[1], m[2], m[3]
+
+	code := name == ".code" || name == ".play"
+	if flags != "" && !code {
+		return items
+	}
+
+	if code && strings.HasPrefix(arg, "-") {
+		edit := Range{Start: Position{pos.Line, pos.Character - utf16Len(arg)}, End: pos}
+
+		for _, flag := range []string{"-numbers", "-edit"} {
+			if strings.HasPrefix(flag, arg) && !strings.Contains(flags, flag) {
+				items = append(items, CompletionItem{
+					Label:    flag,
+					Kind:     CompletionKindKeyword,
+					TextEdit: &TextEdit{edit, flag + " "},
+				})
+			}
+		}
+
+		return items
+	}
+
+	return append(items, pathItems(d, pos, arg)...)
+}
+
+// pathItems returns files to complete arg, a path relative to the slides,
+// at pos.
+func pathItems(d *document, pos Position, arg string) []CompletionItem {
+	if strings.Contains(arg, "://") || strings.HasPrefix(arg, "/") {
+		return nil
+	}
+
+	dir, base := "", arg
+	if i := strings.LastIndex(arg, "/"); i >= 0 {
+		dir, base = arg[:i+1], arg[i+1:]
+	}
+
+	fis, err := ioutil.ReadDir(filepath.Join(filepath.Dir(d.path), filepath.FromSlash(dir)))
+	if err != nil {
+		return nil
+	}
+
+	edit := Range{Start: Position{pos.Line, pos.Character - utf16Len(base)}, End: pos}
+
+	var items []CompletionItem
+	for _, fi := range fis {
+		name := fi.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if name == filepath.Base(d.path) {
+			continue
+		}
+
+		item := CompletionItem{
+			Label: name,
+			Kind:  CompletionKindFile,
+		}
+		if fi.IsDir() {
+			item.Label += "/"
+			item.Kind = CompletionKindFolder
+		}
+		item.TextEdit = &TextEdit{edit, item.Label}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// fileOf returns the file referred by the directive line, relative to the
+// slides, or an empty string if it doesn't refer to a local file.
+func fileOf(line string) string {
+	f := strings.Fields(line)
+	if len(f) < 2 || !fileDirectives[f[0]] {
+		return ""
+	}
+
+	file := f[1]
+	if f[0] == ".code" || f[0] == ".play" {
+		args := renderer.CodeRE.FindStringSubmatch(stripHighlight(line))
+		if args == nil {
+			return ""
+		}
+		file = args[3]
+	}
+
+	if !renderer.IsLocalURL(file) {
+		return ""
+	}
+
+	return file
+}
+
+// stripHighlight removes the HL tag of a .code or .play line.
+func stripHighlight(line string) string {
+	line = strings.TrimSpace(line)
+	if hl := renderer.HighlightRE.FindStringSubmatchIndex(line); len(hl) == 4 {
+		return line[:hl[0]]
+	}
+
+	return line
+}
+
+func highlightOf(line string) string {
+	if m := renderer.HighlightRE.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
+
+// snippet is the part of a file shown by .code or .play.
+type snippet struct {
+	file  string   // path of the file
+	lines []string // as in the file, without ones ending with OMIT
+	nums  []int    // line numbers of lines, starting at 1
+}
+
+// parseSnippet parses the .code or .play line of the slides as present
+// does, which reads the file next to the slides.
+func parseSnippet(d *document, line string) (sn *snippet, err error) {
+	// present panics on some malformed directives
+	defer func() {
+		if e := recover(); e != nil {
+			sn, err = nil, fmt.Errorf("malformed directive: %v", e)
+		}
+	}()
+
+	src := "snippet\n\n* snippet\n\n" + strings.TrimSpace(line) + "\n"
+
+	ctx := &present.Context{ReadFile: ioutil.ReadFile}
+	doc, err := ctx.Parse(strings.NewReader(src), d.path, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(doc.Sections) == 0 || len(doc.Sections[0].Elem) == 0 {
+		return nil, fmt.Errorf("not a snippet")
+	}
+	code, ok := doc.Sections[0].Elem[0].(present.Code)
+	if !ok {
+		return nil, fmt.Errorf("not a snippet")
+	}
+
+	sn = &snippet{
+		file:  filepath.Join(filepath.Dir(d.path), fileOf(line)),
+		lines: strings.Split(strings.TrimSuffix(string(code.Raw), "\n"), "\n"),
+	}
+
+	for _, m := range codeNumRE.FindAllStringSubmatch(string(code.Text), -1) {
+		n, _ := strconv.Atoi(m[1])
+		sn.nums = append(sn.nums, n)
+	}
+	if len(sn.nums) != len(sn.lines) {
+		return nil, fmt.Errorf("lines of the snippet are unknown")
+	}
+
+	return sn, nil
+}
+
+func (s *Server) definition(d *document, pos Position) interface{} {
+	if d == nil || d.format != renderer.FormatPresent {
+		return nil
+	}
+
+	line, _, ok := lineAt(d, pos)
+	if !ok {
+		return nil
+	}
+
+	file := fileOf(line)
+	if file == "" {
+		return nil
+	}
+
+	if sn, err := parseSnippet(d, line); err == nil && len(sn.nums) > 0 {
+		last := sn.nums[len(sn.nums)-1] - 1
+		return Location{
+			URI: uriOf(sn.file),
+			Range: Range{
+				Start: Position{sn.nums[0] - 1, 0},
+				End:   Position{last, utf16Len(sn.lines[len(sn.lines)-1])},
+			},
+		}
+	}
+
+	// the file itself, if the address doesn't match
+	path := filepath.Join(filepath.Dir(d.path), file)
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	return Location{URI: uriOf(path)}
+}
+
+func (s *Server) hover(d *document, pos Position) interface{} {
+	if d == nil || d.format != renderer.FormatPresent {
+		return nil
+	}
+
+	line, _, ok := lineAt(d, pos)
+	if !ok || !strings.HasPrefix(line, ".") {
+		return nil
+	}
+
+	f := strings.Fields(line)
+	r := lineRange([]string{line}, 0)
+	r.Start.Line, r.End.Line = pos.Line, pos.Line
+
+	if f[0] == ".code" || f[0] == ".play" {
+		if sn, err := parseSnippet(d, line); err == nil {
+			return Hover{
+				Contents: MarkupContent{"markdown", preview(sn, highlightOf(line), fileOf(line))},
+				Range:    &r,
+			}
+		}
+	}
+
+	for _, dir := range directives {
+		if dir.name == f[0] {
+			return Hover{
+				Contents: MarkupContent{"markdown", fmt.Sprintf("```\n%s\n```\n\n%s", dir.syntax, dir.doc)},
+				Range:    &r,
+			}
+		}
+	}
+
+	return nil
+}
+
+// preview shows the snippet as it is in the slides, with lines selected
+// and highlighted by the highlight tag.
+func preview(sn *snippet, highlight, name string) string {
+	var code []string
+	var highlighted []string
+
+	for i, l := range sn.lines {
+		if m := renderer.HLCommentRE.FindStringSubmatch(l); m != nil {
+			l = m[1]
+			if m[2] == highlight {
+				highlighted = append(highlighted, strconv.Itoa(sn.nums[i]))
+			}
+		}
+		code = append(code, l)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "**%s** lines %d-%d", name, sn.nums[0], sn.nums[len(sn.nums)-1])
+	if len(highlighted) > 0 {
+		fmt.Fprintf(&b, ", highlighting %s", strings.Join(highlighted, ", "))
+	}
+	fmt.Fprintf(&b, "\n\n```%s\n%s\n```\n", strings.TrimPrefix(filepath.Ext(sn.file), "."), strings.Join(code, "\n"))
+
+	return b.String()
+}
+
+type heading struct {
+	level int
+	name  string
+	line  int
+}
+
+func (s *Server) symbols(d *document) []DocumentSymbol {
+	if d == nil {
+		return []DocumentSymbol{}
+	}
+
+	lines := d.lines()
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+
+	var heads []heading
+	if d.format == renderer.FormatMarkdown {
+		heads = markdownHeadings(lines)
+	} else {
+		heads = presentHeadings(lines)
+	}
+
+	symbols, _ := outline(heads, lines, 0)
+	if symbols == nil {
+		return []DocumentSymbol{}
+	}
+
+	return symbols
+}
+
+// presentHeadings returns headings of slides and sections.
+func presentHeadings(lines []string) []heading {
+	var heads []heading
+	for i, l := range lines {
+		if m := headingRE.FindStringSubmatch(l); m != nil {
+			heads = append(heads, heading{len(m[1]), nameOf(m[2]), i})
+		}
+	}
+
+	return heads
+}
+
+// markdownHeadings returns headings out of fenced code, except the first
+// one which is the title.
+func markdownHeadings(lines []string) []heading {
+	var heads []heading
+	fence := ""
+	title := true
+
+	for i, l := range lines {
+		if m := renderer.MarkdownFenceRE.FindStringSubmatch(l); m != nil {
+			switch {
+			case fence == "":
+				fence = m[1]
+			case strings.HasPrefix(m[1], fence[:1]) && len(m[1]) >= len(fence):
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		if m := renderer.MarkdownHeadingRE.FindStringSubmatch(l); m != nil {
+			if title {
+				title = false
+				continue
+			}
+			heads = append(heads, heading{len(m[1]), nameOf(m[2]), i})
+		}
+	}
+
+	return heads
+}
+
+// nameOf returns the name of a symbol of the heading text, which editors
+// don't allow to be empty.
+func nameOf(text string) string {
+	if text == "" {
+		return "(untitled)"
+	}
+
+	return text
+}
+
+// outline nests heads deeper than level, returning the rest of them. A
+// symbol ranges up to the next heading which is not deeper.
+func outline(heads []heading, lines []string, level int) ([]DocumentSymbol, []heading) {
+	var symbols []DocumentSymbol
+
+	for len(heads) > 0 && heads[0].level > level {
+		h := heads[0]
+
+		var children []DocumentSymbol
+		children, heads = outline(heads[1:], lines, h.level)
+
+		end := len(lines) - 1
+		if len(heads) > 0 {
+			end = heads[0].line - 1
+		}
+		for end > h.line && strings.TrimSpace(lines[end]) == "" {
+			end--
+		}
+
+		symbols = append(symbols, DocumentSymbol{
+			Name: h.name,
+			Kind: SymbolKindString,
+			Range: Range{
+				Start: Position{h.line, 0},
+				End:   Position{end, utf16Len(lines[end])},
+			},
+			SelectionRange: lineRange(lines, h.line),
+			Children:       children,
+		})
+	}
+
+	return symbols, heads
+}
@@ -0,0 +1,375 @@
+// Package lsp serves slides to editors by the language server protocol over
+// a stream such as stdio: diagnostics by the parser and lint, completion of
+// directives and paths of files, definitions of snippets of .code, the
+// outline of sections and previews of snippets on hover.
+package lsp
+
+import (
+	"bufio"
+	"carousel/config"
+	"carousel/playground"
+	"carousel/renderer"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/scryner/logg"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Server is a language server of slides. Only the documents opened by the
+// editor are served, as present reads whole files of slides anyway.
+type Server struct {
+	format string            // format of slides, or config.FormatAuto
+	cache  *playground.Cache // builds .play snippets when saved; nil not to
+
+	mu       sync.Mutex
+	docs     map[string]*document // keyed by URI
+	shutdown bool
+
+	wmu sync.Mutex // serializes writes of messages
+	out io.Writer
+
+	logger *logg.Logger
+}
+
+type document struct {
+	uri     string
+	path    string
+	format  string
+	version int
+	text    string
+}
+
+func (d *document) lines() []string {
+	return strings.Split(d.text, "\n")
+}
+
+// NewServer returns a server reading slides of the format, which may be
+// config.FormatAuto to guess it by extensions. .play snippets are built by
+// cache when documents are opened and saved, which takes a while, unless
+// it is nil.
+func NewServer(format string, cache *playground.Cache) *Server {
+	return &Server{
+		format: format,
+		cache:  cache,
+		docs:   make(map[string]*document),
+		logger: logg.GetDefaultLogger("lsp"),
+	}
+}
+
+var errNoShutdown = errors.New("exited without shutdown")
+
+// Serve reads requests from r and writes responses to w until the client
+// asks to exit, or r is closed. An error is returned unless the client shut
+// the server down before.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.out = w
+	br := bufio.NewReader(r)
+
+	for {
+		b, err := readMessage(br)
+		if err != nil {
+			if err == io.EOF && s.isShutdown() {
+				return nil
+			}
+			return fmt.Errorf("reading message: %v", err)
+		}
+
+		var req request
+		if err := json.Unmarshal(b, &req); err != nil {
+			s.reply(nil, nil, &responseError{codeParseError, err.Error()})
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.isShutdown() {
+				return errNoShutdown
+			}
+			return nil
+		}
+
+		s.logger.Debugf("%s", req.Method)
+
+		result, err := s.handle(req.Method, req.Params)
+		if req.ID == nil {
+			// notifications get no response
+			if err != nil && err != errUnknownMethod {
+				s.logger.Warnf("%s: %v", req.Method, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			rerr, ok := err.(*responseError)
+			if !ok {
+				rerr = &responseError{codeInternalError, err.Error()}
+			}
+			s.reply(req.ID, nil, rerr)
+			continue
+		}
+
+		s.reply(req.ID, result, nil)
+	}
+}
+
+func (s *Server) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.shutdown
+}
+
+var errUnknownMethod = &responseError{codeMethodNotFound, "method not found"}
+
+func (s *Server) handle(method string, params json.RawMessage) (interface{}, error) {
+	if s.isShutdown() && method != "shutdown" {
+		return nil, &responseError{codeInvalidRequest, "server is shut down"}
+	}
+
+	switch method {
+	case "initialize":
+		return s.initialize(), nil
+
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.didOpen(p)
+
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.didChange(p)
+
+	case "textDocument/didSave":
+		var p DidSaveTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.didSave(p)
+
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		s.didClose(p)
+		return nil, nil
+
+	case "textDocument/completion":
+		var p TextDocumentPositionParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.completion(s.document(p.TextDocument.URI), p.Position), nil
+
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(s.document(p.TextDocument.URI), p.Position), nil
+
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.symbols(s.document(p.TextDocument.URI)), nil
+
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(s.document(p.TextDocument.URI), p.Position), nil
+	}
+
+	return nil, errUnknownMethod
+}
+
+func unmarshalParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{codeInvalidParams, err.Error()}
+	}
+
+	return nil
+}
+
+func (s *Server) initialize() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				"change":    1, // full
+				"save":      map[string]interface{}{"includeText": false},
+			},
+			"completionProvider": map[string]interface{}{
+				"triggerCharacters": []string{".", "/"},
+			},
+			"definitionProvider":     true,
+			"documentSymbolProvider": true,
+			"hoverProvider":          true,
+		},
+		"serverInfo": map[string]interface{}{
+			"name": "carousel",
+		},
+	}
+}
+
+// document returns a snapshot of the opened document, or nil if it isn't
+// opened.
+func (s *Server) document(uri string) *document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil
+	}
+
+	snapshot := *d
+	return &snapshot
+}
+
+func (s *Server) didOpen(p DidOpenTextDocumentParams) error {
+	path, err := pathOf(p.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+
+	format := s.format
+	if format == config.FormatAuto {
+		format = renderer.FormatOf(path)
+	}
+
+	d := &document{
+		uri:     p.TextDocument.URI,
+		path:    path,
+		format:  format,
+		version: p.TextDocument.Version,
+		text:    p.TextDocument.Text,
+	}
+
+	s.mu.Lock()
+	s.docs[d.uri] = d
+	s.mu.Unlock()
+
+	s.diagnose(d.uri, true)
+	return nil
+}
+
+func (s *Server) didChange(p DidChangeTextDocumentParams) error {
+	s.mu.Lock()
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%s is not opened", p.TextDocument.URI)
+	}
+
+	for _, change := range p.ContentChanges {
+		if change.Range == nil {
+			d.text = change.Text
+			continue
+		}
+
+		start := offsetOf(d.text, change.Range.Start)
+		end := offsetOf(d.text, change.Range.End)
+		if end < start {
+			start, end = end, start
+		}
+		d.text = d.text[:start] + change.Text + d.text[end:]
+	}
+	d.version = p.TextDocument.Version
+	s.mu.Unlock()
+
+	// building on every change would be too slow
+	s.diagnose(p.TextDocument.URI, false)
+	return nil
+}
+
+func (s *Server) didSave(p DidSaveTextDocumentParams) error {
+	s.mu.Lock()
+	d, ok := s.docs[p.TextDocument.URI]
+	if ok && p.Text != nil {
+		d.text = *p.Text
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%s is not opened", p.TextDocument.URI)
+	}
+
+	s.diagnose(p.TextDocument.URI, true)
+	return nil
+}
+
+func (s *Server) didClose(p DidCloseTextDocumentParams) {
+	s.mu.Lock()
+	delete(s.docs, p.TextDocument.URI)
+	s.mu.Unlock()
+
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// offsetOf returns the offset in text of the position.
+func offsetOf(text string, pos Position) int {
+	offset := 0
+	for i := 0; i < pos.Line; i++ {
+		j := strings.IndexByte(text[offset:], '\n')
+		if j < 0 {
+			return len(text)
+		}
+		offset += j + 1
+	}
+
+	line := text[offset:]
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+
+	return offset + byteOffset(line, pos.Character)
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, err *responseError) {
+	if err != nil {
+		s.write(errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+		return
+	}
+
+	s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	s.write(notification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+func (s *Server) write(v interface{}) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := writeMessage(s.out, v); err != nil {
+		s.logger.Errorf("failed to write message: %v", err)
+	}
+}
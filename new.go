@@ -0,0 +1,166 @@
+package main
+
+import (
+	"carousel/config"
+	"carousel/scaffold"
+	"carousel/theme"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var newCommand = &command{
+	name:  "new",
+	args:  "directory",
+	short: "create a directory of new slides from a template",
+	long: `
+New creates a directory of new slides from a template: slides whose header
+is filled, a sample snippet of .play, an images directory and a config file.
+
+The author and the email are taken from git config unless given by flags.
+Templates are built in, or directories under the templates directory of
+the config, whose files are copied into the new directory. Files named
+with .tmpl are executed as text/template with .Name, .Title, .Subtitle,
+.Author, .Email, .Date and .Theme, and .tmpl is removed from their names.`,
+	flags: newFlags,
+	run:   runNew,
+}
+
+var (
+	newTemplate string
+	newList     bool
+	newDeck     scaffold.Deck
+	newDate     string
+)
+
+func newFlags(fs *flag.FlagSet) {
+	fs.StringVar(&newTemplate, "template", scaffold.Default, "template of slides: "+strings.Join(scaffold.Builtins(), ", ")+", one in the templates directory or a directory")
+	fs.BoolVar(&newList, "list", false, "list templates and exit")
+	fs.StringVar(&newDeck.Title, "title", "", "title of slides (default is made from the directory name)")
+	fs.StringVar(&newDeck.Subtitle, "subtitle", "", "subtitle of slides")
+	fs.StringVar(&newDeck.Author, "author", "", "author of slides (default is user.name of git config)")
+	fs.StringVar(&newDeck.Email, "email", "", "email of the author (default is user.email of git config)")
+	fs.StringVar(&newDate, "date", "", "date of the talk as 2006-01-02 (default is today)")
+	fs.StringVar(&newDeck.Theme, "theme", "", "theme written in the config file: "+strings.Join(theme.Builtins(), ", ")+" or a directory")
+	fs.StringVar(&cfg.Templates, "templates", cfg.Templates, "directory of templates")
+	fs.StringVar(&configFile, "config", "", "config file used instead of the user config ("+config.UserFile()+")")
+}
+
+func runNew(cmd *command, fs *flag.FlagSet) int {
+	if err := loadConfig(fs, ""); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	if newList {
+		for _, name := range scaffold.Builtins() {
+			fmt.Printf("%s (built-in)\n", name)
+		}
+		for _, name := range scaffold.List(cfg.Templates) {
+			fmt.Println(name)
+		}
+		return exitOK
+	}
+
+	if fs.NArg() != 1 {
+		return cmd.usageError("one directory must be given")
+	}
+
+	target := fs.Arg(0)
+
+	t, err := scaffold.Load(newTemplate, cfg.Templates)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	deck := newDeck
+	deck.Name = filepath.Base(target)
+
+	if deck.Title == "" {
+		deck.Title = titleOf(deck.Name)
+	}
+	if deck.Author == "" {
+		deck.Author = gitConfig("user.name")
+	}
+	if deck.Email == "" {
+		deck.Email = gitConfig("user.email")
+	}
+
+	deck.Date = time.Now()
+	if newDate != "" {
+		if deck.Date, err = time.Parse("2006-01-02", newDate); err != nil {
+			return cmd.usageError("date '%s' is not like 2006-01-02", newDate)
+		}
+	}
+
+	if deck.Theme != "" && !theme.IsBuiltin(deck.Theme) {
+		// the config file reads a theme directory relatively to itself
+		if deck.Theme, err = relativeTo(target, deck.Theme); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	}
+
+	created, err := t.Create(target, deck)
+	for _, file := range created {
+		fmt.Printf("created %s\n", file)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create '%s': %v\n", target, err)
+		return exitFailure
+	}
+
+	return exitOK
+}
+
+// titleOf makes a title from the name of a directory, such as "My talk"
+// of "my-talk".
+func titleOf(name string) string {
+	title := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' {
+			return ' '
+		}
+		return r
+	}, name)
+	if title == "" {
+		return ""
+	}
+
+	r, n := utf8.DecodeRuneInString(title)
+	return string(unicode.ToUpper(r)) + title[n:]
+}
+
+// gitConfig returns the value of the key in git config, or an empty string
+// if git or the key isn't there.
+func gitConfig(key string) string {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// relativeTo returns path, which is relative to the working directory,
+// relatively to dir.
+func relativeTo(dir, path string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Rel(absDir, absPath)
+}
@@ -0,0 +1,290 @@
+package playground
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache keeps binaries of Go programs built, keyed by hashes of their
+// source, the version of Go and the race flag, so that running a program
+// again only executes it. Binaries are kept in a directory, which survives
+// restarts and is never cleaned up by carousel; remove it to clear the
+// cache. A cache of no directory only builds programs.
+type Cache struct {
+	dir    string
+	limits Limits // of builds
+
+	once    sync.Once
+	env     string // of the go tool, which binaries depend on
+	envErr  error
+	mu      sync.Mutex
+	pending map[string]chan struct{} // builds in progress by key
+}
+
+// NewCache returns a cache of binaries in dir, which is created when the
+// first binary is stored. dir may be empty not to keep binaries. Programs
+// are built within the limits, as they are before runs.
+func NewCache(dir string, limits Limits) *Cache {
+	return &Cache{
+		dir:     dir,
+		limits:  limits,
+		pending: make(map[string]chan struct{}),
+	}
+}
+
+// Dir returns the directory of binaries, or "" if they are not kept.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// key returns the key of the binary of the program.
+func (c *Cache) key(body string, race bool) (string, error) {
+	c.once.Do(func() {
+		cmd := exec.Command("go", "env", "GOVERSION", "GOOS", "GOARCH")
+		cmd.Env = Environ()
+
+		out, err := cmd.Output()
+		if err != nil {
+			c.envErr = fmt.Errorf("can't tell the version of Go: %v", err)
+			return
+		}
+		c.env = string(out)
+	})
+
+	if c.envErr != nil {
+		return "", c.envErr
+	}
+
+	h := sha256.New()
+	// surrounding spaces differ by how snippets are taken from pages
+	fmt.Fprintf(h, "%s\x00%s\x00%v", strings.TrimSpace(body), c.env, race)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (c *Cache) path(key string) string {
+	path := filepath.Join(c.dir, key)
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+
+	return path
+}
+
+// lookup returns the binary of the key, waiting for it if it is being
+// built. It is "" if the binary is not cached.
+func (c *Cache) lookup(key string) string {
+	if c.dir == "" {
+		return ""
+	}
+
+	c.mu.Lock()
+	wait := c.pending[key]
+	c.mu.Unlock()
+
+	if wait != nil {
+		<-wait
+	}
+
+	path := c.path(key)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+
+	return path
+}
+
+// begin marks the binary of the key being built, and returns the function
+// to call when it is done. It returns nil if another build of it is in
+// progress.
+func (c *Cache) begin(key string) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.pending[key]; ok {
+		return nil
+	}
+
+	done := make(chan struct{})
+	c.pending[key] = done
+
+	return func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+
+		close(done)
+	}
+}
+
+// store copies the binary built at bin into the cache. The binary appears
+// at once, so that others never run a partial one.
+func (c *Cache) store(key, bin string) error {
+	if c.dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+
+	src, err := os.Open(bin)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := ioutil.TempFile(c.dir, "building-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+
+	_, err = io.Copy(dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(dst.Name(), 0755)
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(dst.Name(), c.path(key))
+}
+
+// Build builds the Go program as the playground runs it, unless it is
+// cached. The error of a failed build has messages of the compiler.
+func (c *Cache) Build(body string, race bool) error {
+	key, err := c.key(body, race)
+	if err != nil {
+		return err
+	}
+
+	if c.lookup(key) != "" {
+		return nil
+	}
+
+	if done := c.begin(key); done != nil {
+		defer done()
+	} else if c.lookup(key) != "" {
+		// built by another meanwhile
+		return nil
+	}
+
+	dir, err := ioutil.TempDir("", "carousel-build")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// lines of errors are those of the snippet, not of the page
+	src := strings.TrimSpace(body) + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "prog.go"), []byte(src), 0666); err != nil {
+		return err
+	}
+
+	bin := filepath.Join(dir, "prog")
+	args := goBuild(bin, &Options{Race: race})
+
+	var out bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = Environ()
+	cmd.Stdout, cmd.Stderr = &out, &out
+	limit(cmd, buildLimits(c.limits))
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var timer *time.Timer
+	if c.limits.Timeout > 0 {
+		timer = time.AfterFunc(c.limits.Timeout, func() {
+			killProcess(cmd)
+		})
+	}
+
+	err = cmd.Wait()
+	if timer != nil && !timer.Stop() {
+		return fmt.Errorf("timed out after %v", c.limits.Timeout)
+	}
+
+	if err != nil {
+		var msgs []string
+		for _, s := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			// skip "# command-line-arguments"
+			if s != "" && !strings.HasPrefix(s, "#") {
+				msgs = append(msgs, strings.TrimSpace(s))
+			}
+		}
+		if len(msgs) == 0 {
+			return err
+		}
+
+		return errors.New(strings.Join(msgs, "; "))
+	}
+
+	return c.store(key, bin)
+}
+
+// Source is a program of slides to be built before it is run.
+type Source struct {
+	Name string // to be logged, such as the file of the snippet
+	Body string // as sent by browsers to run
+	Ext  string // extension of the snippet, which selects the runner
+}
+
+// Prebuild builds programs of Go in the background and keeps their
+// binaries in the cache, so that they start at once when run. Failures are
+// logged. Programs of one call are built one by one after those of earlier
+// calls, not to keep the machine busy, and each takes a slot of runs. It
+// does nothing without the cache.
+func (pg *Playground) Prebuild(deck string, sources []Source) {
+	if pg.cache == nil {
+		return
+	}
+
+	go func() {
+		pg.prebuilding.Lock()
+		defer pg.prebuilding.Unlock()
+
+		ready, failed := 0, 0
+		for _, src := range sources {
+			r, err := pg.runnerOf(src.Body, &Options{Ext: src.Ext})
+			if err != nil || r.name != "go" {
+				continue
+			}
+
+			if pg.slots != nil {
+				pg.slots <- struct{}{}
+			}
+			err = pg.cache.Build(src.Body, false)
+			pg.release()
+
+			if err != nil {
+				pg.logger.Errorf("%s of '%s' doesn't build: %v", src.Name, deck, err)
+				failed++
+				continue
+			}
+			ready++
+		}
+
+		if ready+failed > 0 {
+			pg.logger.Infof("%d snippets of '%s' ready to run, %d failed to build", ready, deck, failed)
+		}
+	}()
+}
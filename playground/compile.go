@@ -0,0 +1,122 @@
+package playground
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// internal kinds of messages of /compile
+const (
+	kindBuild = "build"
+	kindStart = "start"
+)
+
+// compileResponse is the response of /compile in the format of the Go
+// playground, which HTTPTransport of play.js plays back.
+type compileResponse struct {
+	Errors string
+	Events []event
+}
+
+type event struct {
+	Message string
+	Kind    string        // stdout, stderr or system
+	Delay   time.Duration // since the previous event, or the start
+}
+
+// CompileHandler returns the handler of /compile, which builds and runs
+// the program of the form value "body" within the limits, and responds
+// with its output and timing after it ends. The form value "ext" is the
+// extension of the snippet, which selects the runner.
+func (pg *Playground) CompileHandler() http.HandlerFunc {
+	return pg.compile
+}
+
+func (pg *Playground) compile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pg.logger.Debugf("compiling snippet from %s", r.RemoteAddr)
+
+	var resp compileResponse
+	if pg.replays != nil {
+		// play.js plays back the timing anyway
+		resp = pg.replays.response(r.FormValue("body"))
+	} else {
+		opt := &Options{Ext: r.FormValue("ext")}
+		resp = pg.collect("compile", r.FormValue("body"), opt).response()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		pg.logger.Errorf("failed to respond to /compile: %v", err)
+	}
+}
+
+// collect runs the program to its end, collecting its output with timing
+// as the Go playground does.
+func (pg *Playground) collect(id, body string, opt *Options) *Recording {
+	c := &conn{
+		out:       make(chan *Message),
+		done:      make(chan struct{}),
+		buildKind: kindBuild,
+		startKind: kindStart,
+	}
+	defer close(c.done)
+
+	pg.start(c, id, body, opt)
+
+	rec := &Recording{Events: []event{}}
+	var last time.Time // of the last event; zero until the program starts
+
+	for m := range c.out {
+		if m.Kind == "end" {
+			rec.End = m.Body
+			if !last.IsZero() {
+				rec.EndDelay = time.Since(last)
+			}
+			break
+		}
+
+		switch m.Kind {
+		case kindBuild:
+			rec.Errors += m.Body
+
+		case kindStart:
+			rec.Started = true
+			last = time.Now()
+
+		case "stdout", "stderr", "system":
+			now, delay := time.Now(), time.Duration(0)
+			if !last.IsZero() {
+				delay = now.Sub(last)
+				last = now
+			}
+			rec.Events = append(rec.Events, event{m.Body, m.Kind, delay})
+		}
+	}
+
+	return rec
+}
+
+// response returns the response of /compile of the output.
+func (rec *Recording) response() compileResponse {
+	// recordings are shared by replays
+	resp := compileResponse{Errors: rec.Errors, Events: append([]event{}, rec.Events...)}
+
+	switch {
+	case rec.End == "":
+	case !rec.Started:
+		// failed to build; the go tool tells why
+		if resp.Errors == "" {
+			resp.Errors = rec.End
+		}
+	default:
+		resp.Events = append(resp.Events, event{"\n" + rec.End + "\n", "stderr", rec.EndDelay})
+	}
+
+	return resp
+}
@@ -0,0 +1,54 @@
+package playground
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RlimitsSupported tells whether limits of CPU, memory and open files work.
+const RlimitsSupported = true
+
+// limit makes cmd run within the limits by setting rlimits in a shell,
+// which then execs the program. The program gets a process group of its
+// own, so that killing it kills its children as well.
+func limit(cmd *exec.Cmd, l Limits) {
+	var ulimits []string
+	if l.CPU > 0 {
+		// SIGXCPU at the soft limit is ignored by Go programs, and SIGKILL
+		// at the hard one ends them
+		secs := int64((l.CPU + time.Second - 1) / time.Second)
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -St %d && ulimit -Ht %d", secs, secs+1))
+	}
+	if l.Memory > 0 {
+		// the data segment, which has anonymous mappings of the heap since
+		// Linux 4.7, as the address space reserved by the Go runtime is much
+		// larger than the memory used
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -d %d", (l.Memory+1023)/1024))
+	}
+	if l.OpenFiles > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -n %d", l.OpenFiles))
+	}
+
+	if len(ulimits) > 0 {
+		script := strings.Join(ulimits, " && ") + ` && exec "$@"`
+		cmd.Args = append([]string{"sh", "-c", script, "sh", cmd.Path}, cmd.Args[1:]...)
+		cmd.Path = "/bin/sh"
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcess kills the process group of cmd.
+func killProcess(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.Process.Kill()
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package playground
+
+import (
+	"os/exec"
+)
+
+// RlimitsSupported tells whether limits of CPU, memory and open files work.
+const RlimitsSupported = false
+
+// limit does nothing, as rlimits are only on Linux; the timeout and the
+// output limit still work.
+func limit(cmd *exec.Cmd, l Limits) {
+}
+
+func killProcess(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,284 @@
+// Package playground runs snippets of slides for the local playground over
+// websockets, in the wire format of the playground socket of present, or by
+// HTTP as /compile of the Go playground does. Unlike the socket of present,
+// runs are limited in time, CPU, memory, open files
+// and output as Limits say, and only a number of them run at once while
+// others wait in a queue. A program is built and run in a temporary
+// directory of its own, which is removed when it ends, and binaries of Go
+// may be kept in a Cache to run at once next time.
+package playground
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/scryner/logg"
+	"golang.org/x/net/websocket"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is the wire format of the websocket, used for both commands
+// from browsers and output of programs as distinguished by Kind.
+type Message struct {
+	Id      string   // client-provided unique id for the process
+	Kind    string   // in: "run", "kill" out: "stdout", "stderr", "system", "end"
+	Body    string   // program, output, or the error of "end"
+	Options *Options `json:",omitempty"`
+}
+
+// Options are options of "run".
+type Options struct {
+	Race bool   // use -race flag when building code
+	Ext  string // extension of the snippet, which selects the runner
+}
+
+// Limits limit each run of a program. Zero means no limit. Limits of CPU,
+// memory and open files are set by rlimits, which are only on Linux.
+type Limits struct {
+	Timeout   time.Duration // wall-clock time of running the program
+	CPU       time.Duration // CPU time of the program
+	Memory    int64         // bytes of data of the program
+	OpenFiles int           // files opened by the program at once
+	Output    int64         // bytes of stdout and stderr, including the build
+	Runs      int           // programs run at once; more wait in a queue
+}
+
+func (l Limits) String() string {
+	var s []string
+
+	add := func(name string, value interface{}, set bool) {
+		if set {
+			s = append(s, fmt.Sprintf("%s %v", name, value))
+		}
+	}
+	add("timeout", l.Timeout, l.Timeout > 0)
+	add("CPU", l.CPU, l.CPU > 0 && RlimitsSupported)
+	add("memory", byteSize(l.Memory), l.Memory > 0 && RlimitsSupported)
+	add("open files", l.OpenFiles, l.OpenFiles > 0 && RlimitsSupported)
+	add("output", byteSize(l.Output), l.Output > 0)
+	add("runs at once", l.Runs, l.Runs > 0)
+
+	if len(s) == 0 {
+		return "no limits"
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// byteSize formats n bytes such as "256 MB".
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// Environ provides the environment of the go tool and programs run.
+var Environ func() []string = os.Environ
+
+// Playground runs programs sent through websockets.
+type Playground struct {
+	limits  Limits
+	runners map[string]bool // enabled by name
+	slots   chan struct{}   // nil if runs are not limited
+	cache   *Cache          // of binaries of Go; nil if not cached
+	replays *replays        // replayed instead of running programs, if not nil
+
+	prebuilding sync.Mutex // held while prebuilding, one deck at a time
+
+	logger *logg.Logger
+}
+
+// New returns a playground running programs within limits. Programs of Go
+// are always run, and others only if their runners are enabled by names.
+// Binaries of Go are kept in cache if it is not nil.
+func New(limits Limits, runnerNames []string, cache *Cache) (*Playground, error) {
+	pg := &Playground{
+		limits:  limits,
+		runners: map[string]bool{"go": true},
+		cache:   cache,
+		logger:  logg.GetDefaultLogger("playground"),
+	}
+
+	for _, name := range runnerNames {
+		if runnerNamed(name) == nil {
+			return nil, fmt.Errorf("unknown runner '%s'; runners are %s", name, strings.Join(Runners(), ", "))
+		}
+		pg.runners[name] = true
+	}
+
+	if limits.Runs > 0 {
+		pg.slots = make(chan struct{}, limits.Runs)
+	}
+
+	return pg, nil
+}
+
+// Runners returns names of runners enabled, and commands which they need
+// but are not found.
+func (pg *Playground) Runners() (enabled, missing []string) {
+	for _, r := range runners {
+		if !pg.runners[r.name] {
+			continue
+		}
+		enabled = append(enabled, r.name)
+
+		if _, err := exec.LookPath(r.command()); err != nil {
+			missing = append(missing, r.command())
+		}
+	}
+
+	return enabled, missing
+}
+
+// Cache returns the cache of binaries, or nil if they are not cached.
+func (pg *Playground) Cache() *Cache {
+	return pg.cache
+}
+
+// Limits returns the limits of runs.
+func (pg *Playground) Limits() Limits {
+	return pg.limits
+}
+
+// conn is a connection, to which output of its programs is sent.
+type conn struct {
+	out  chan *Message
+	done chan struct{} // closed when the connection is gone
+
+	// kinds of output of the go tool and starts of programs; starts are
+	// sent only if it is not empty
+	buildKind, startKind string
+}
+
+// send sends m unless the connection is gone.
+func (c *conn) send(m *Message) {
+	select {
+	case c.out <- m:
+	case <-c.done:
+	}
+}
+
+// Handler returns the handler of websockets. Programs of a connection are
+// killed when it is closed.
+func (pg *Playground) Handler() websocket.Handler {
+	return pg.serve
+}
+
+func (pg *Playground) serve(ws *websocket.Conn) {
+	procs := make(map[string]*process)
+	defer func() {
+		for _, p := range procs {
+			p.kill("")
+			<-p.done
+		}
+	}()
+
+	c := &conn{
+		out:       make(chan *Message),
+		done:      make(chan struct{}),
+		buildKind: "stderr",
+	}
+	// before killing programs, which can't send output any more
+	defer close(c.done)
+
+	in := make(chan *Message)
+	errc := make(chan error, 2)
+
+	go func() {
+		dec := json.NewDecoder(ws)
+		for {
+			var m Message
+			if err := dec.Decode(&m); err != nil {
+				errc <- err
+				return
+			}
+
+			select {
+			case in <- &m:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		enc := json.NewEncoder(ws)
+		for {
+			select {
+			case m := <-c.out:
+				if err := enc.Encode(m); err != nil {
+					errc <- err
+					return
+				}
+			case <-c.done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case m := <-in:
+			switch m.Kind {
+			case "run":
+				pg.logger.Debugf("running snippet from %s", ws.Request().RemoteAddr)
+
+				if p := procs[m.Id]; p != nil {
+					p.kill("")
+					<-p.done
+				}
+				procs[m.Id] = pg.start(c, m.Id, m.Body, m.Options)
+
+			case "kill":
+				if p := procs[m.Id]; p != nil {
+					p.kill("")
+				}
+			}
+
+		case err := <-errc:
+			if err != io.EOF {
+				pg.logger.Debugf("playground connection: %v", err)
+			}
+			ws.Close()
+			return
+		}
+	}
+}
+
+// acquire waits for a slot of runs, telling the client if it has to. It
+// returns false if the process is killed meanwhile.
+func (pg *Playground) acquire(p *process) bool {
+	if pg.slots == nil {
+		return true
+	}
+
+	select {
+	case pg.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	p.c.send(&Message{Id: p.id, Kind: "system", Body: "Waiting for other programs to finish...\n"})
+
+	select {
+	case pg.slots <- struct{}{}:
+		return true
+	case <-p.cancel:
+		return false
+	}
+}
+
+func (pg *Playground) release() {
+	if pg.slots != nil {
+		<-pg.slots
+	}
+}
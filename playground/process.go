@@ -0,0 +1,322 @@
+package playground
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Batch output written in this interval and send as a single message.
+const msgDelay = 10 * time.Millisecond
+
+var errKilled = errors.New("killed")
+
+// process is a run of a program, from waiting in the queue to its end.
+type process struct {
+	pg     *Playground
+	id     string
+	c      *conn
+	limits Limits
+
+	stdout, stderr *output
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd     // the go tool or the program running, if any
+	reason  string        // why the process was killed by a limit
+	written int64         // bytes of output
+	oom     bool          // the program ran out of memory
+	cancel  chan struct{} // closed when killed
+	done    chan struct{} // closed when the end is sent
+}
+
+// start runs the program of body in the background, sending its output and
+// end to the connection.
+func (pg *Playground) start(c *conn, id, body string, opt *Options) *process {
+	p := &process{
+		pg:     pg,
+		id:     id,
+		c:      c,
+		limits: pg.limits,
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	p.stdout = &output{p: p, kind: "stdout"}
+	p.stderr = &output{p: p, kind: "stderr"}
+
+	go func() {
+		defer close(p.done)
+
+		err := p.run(body, opt)
+
+		p.stdout.flush()
+		p.stderr.flush()
+		p.end(err)
+	}()
+
+	return p
+}
+
+// kill stops the process. reason tells the limit exceeded, if it is killed
+// by one.
+func (p *process) kill(reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.cancel:
+		return
+	default:
+	}
+
+	p.reason = reason
+	close(p.cancel)
+
+	if p.cmd != nil {
+		killProcess(p.cmd)
+	}
+}
+
+// run builds and runs the program in a temporary directory, which is
+// removed when it ends, or replays its output if the playground does.
+func (p *process) run(body string, opt *Options) error {
+	if p.pg.replays != nil {
+		return p.replay(body)
+	}
+
+	r, err := p.pg.runnerOf(body, opt)
+	if err != nil {
+		return err
+	}
+
+	if !p.pg.acquire(p) {
+		return errKilled
+	}
+	defer p.pg.release()
+
+	dir, err := ioutil.TempDir("", "carousel-play")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// the timeout covers the build as well
+	if p.limits.Timeout > 0 {
+		timer := time.AfterFunc(p.limits.Timeout, func() {
+			p.kill(fmt.Sprintf("timed out after %v", p.limits.Timeout))
+		})
+		defer timer.Stop()
+	}
+
+	cmd, err := p.prepare(r, dir, body, opt)
+	if err != nil {
+		return p.violation(err, nil)
+	}
+
+	limit(cmd, p.limits)
+
+	if p.c.startKind != "" {
+		p.c.send(&Message{Id: p.id, Kind: p.c.startKind})
+	}
+
+	if err := p.exec(cmd); err != nil {
+		// a non-main package builds, but can't be run
+		if name, perr := packageName(body); r.name == "go" && perr == nil && name != "main" {
+			return errors.New(`executable programs must use "package main"`)
+		}
+		return err
+	}
+
+	return p.violation(cmd.Wait(), cmd.ProcessState)
+}
+
+// command returns the command writing its output to the client.
+func (p *process) command(dir, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = Environ()
+	cmd.Stdout = p.stdout
+	cmd.Stderr = p.stderr
+
+	return cmd
+}
+
+// exec starts cmd unless the process is killed already, so that killing it
+// later kills cmd.
+func (p *process) exec(cmd *exec.Cmd) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.cancel:
+		return errKilled
+	default:
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.cmd = cmd
+
+	return nil
+}
+
+// violation returns the error of the program telling the limit exceeded, if
+// it is so.
+func (p *process) violation(err error, state *os.ProcessState) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case err == nil:
+		return nil
+	case p.reason != "":
+		return errors.New(p.reason)
+	case p.limits.CPU > 0 && RlimitsSupported && state != nil && state.UserTime()+state.SystemTime() >= p.limits.CPU:
+		return fmt.Errorf("CPU time limit of %v exceeded", p.limits.CPU)
+	case p.limits.Memory > 0 && RlimitsSupported && state != nil && p.oom:
+		return fmt.Errorf("memory limit of %s exceeded", byteSize(p.limits.Memory))
+	}
+
+	return err
+}
+
+// end sends the end of the process with the error, if any.
+func (p *process) end(err error) {
+	m := &Message{Id: p.id, Kind: "end"}
+	if err != nil {
+		m.Body = err.Error()
+	}
+
+	p.c.send(m)
+}
+
+// shebang looks for a shebang ('#!') at the beginning of the passed string.
+// If found, it returns the path and args after the shebang.
+// args includes the command as args[0].
+func shebang(body string) (path string, args []string) {
+	body = strings.TrimSpace(body)
+	if !strings.HasPrefix(body, "#!") {
+		return "", nil
+	}
+	if i := strings.Index(body, "\n"); i >= 0 {
+		body = body[:i]
+	}
+
+	fs := strings.Fields(body[2:])
+	if len(fs) == 0 {
+		return "", nil
+	}
+
+	return fs[0], fs
+}
+
+func packageName(body string) (string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "prog.go", strings.NewReader(body), parser.PackageClauseOnly)
+	if err != nil {
+		return "", err
+	}
+
+	return f.Name.String(), nil
+}
+
+// allow returns the part of output b within the limit, killing the process
+// if it is exceeded.
+func (p *process) allow(b []byte, kind string) []byte {
+	p.mu.Lock()
+
+	exceeded := false
+	if p.limits.Output > 0 {
+		left := p.limits.Output - p.written
+		if int64(len(b)) > left {
+			if left < 0 {
+				left = 0
+			}
+			b = b[:left]
+			exceeded = true
+		}
+	}
+	p.written += int64(len(b))
+
+	// as the Go runtime says when failing to allocate
+	if kind == "stderr" && (bytes.Contains(b, []byte("out of memory")) || bytes.Contains(b, []byte("cannot allocate memory"))) {
+		p.oom = true
+	}
+
+	p.mu.Unlock()
+
+	if exceeded {
+		p.kill(fmt.Sprintf("output exceeded %s", byteSize(p.limits.Output)))
+	}
+
+	return b
+}
+
+// output is an io.Writer sending writes of a kind to the client. Writes
+// in a short period are sent as one message.
+type output struct {
+	p    *process
+	kind string
+
+	mu   sync.Mutex
+	buf  []byte
+	send *time.Timer
+}
+
+func (w *output) Write(b []byte) (int, error) {
+	n := len(b)
+
+	b = w.p.allow(b, w.kind)
+	if len(b) == 0 {
+		return n, nil
+	}
+
+	w.mu.Lock()
+	w.buf = append(w.buf, b...)
+	if w.send == nil {
+		w.send = time.AfterFunc(msgDelay, w.flush)
+	}
+	w.mu.Unlock()
+
+	return n, nil
+}
+
+// flush sends output buffered.
+func (w *output) flush() {
+	w.mu.Lock()
+	if w.send != nil {
+		w.send.Stop()
+		w.send = nil
+	}
+	body := w.buf
+	w.buf = nil
+	w.mu.Unlock()
+
+	if len(body) > 0 {
+		w.p.c.send(&Message{Id: w.p.id, Kind: w.kind, Body: safeString(body)})
+	}
+}
+
+// safeString returns b as a valid UTF-8 string.
+func safeString(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+
+	var buf bytes.Buffer
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		buf.WriteRune(r)
+	}
+
+	return buf.String()
+}
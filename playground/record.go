@@ -0,0 +1,225 @@
+package playground
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordingsExt is the extension of files of recordings, which are put
+// next to slides.
+const RecordingsExt = ".play.json"
+
+// Recording is the output of a program with its timing, recorded to be
+// replayed where it can't run.
+type Recording struct {
+	Time     time.Time     // when it was recorded
+	Errors   string        `json:",omitempty"` // of the build
+	Started  bool          // false if it failed to build
+	Events   []event       // output of the program
+	End      string        `json:",omitempty"` // error of the end
+	EndDelay time.Duration // since the last event
+}
+
+// Recordings are recordings of snippets keyed by hashes of their source.
+type Recordings map[string]*Recording
+
+// RecordingsFile returns the file of recordings of the slides.
+func RecordingsFile(slides string) string {
+	return strings.TrimSuffix(slides, filepath.Ext(slides)) + RecordingsExt
+}
+
+// ReadRecordings reads recordings of the file.
+func ReadRecordings(file string) (Recordings, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var recs Recordings
+	if err := json.Unmarshal(b, &recs); err != nil {
+		return nil, fmt.Errorf("while reading recordings '%s': %v", file, err)
+	}
+
+	return recs, nil
+}
+
+// Write writes the recordings to the file.
+func (recs Recordings) Write(file string) error {
+	b, err := json.MarshalIndent(recs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, append(b, '\n'), 0644)
+}
+
+// Add puts the recording of the program in the recordings.
+func (recs Recordings) Add(body string, rec *Recording) {
+	recs[recordingKey(body)] = rec
+}
+
+// recordingKey returns the key of the program in recordings. Surrounding
+// spaces differ by how snippets are taken from pages.
+func recordingKey(body string) string {
+	h := sha256.Sum256([]byte(strings.TrimSpace(body)))
+	return hex.EncodeToString(h[:])
+}
+
+// Record runs the program within the limits, and returns its output with
+// timing to be replayed.
+func (pg *Playground) Record(body string, opt *Options) *Recording {
+	rec := pg.collect("record", body, opt)
+	rec.Time = time.Now()
+
+	return rec
+}
+
+// Replay makes the playground replay output recorded in files instead of
+// running programs. Files are read again when they are modified.
+func (pg *Playground) Replay(files []string) {
+	pg.replays = &replays{files: files}
+}
+
+// Replaying tells whether the playground replays recorded output.
+func (pg *Playground) Replaying() bool {
+	return pg.replays != nil
+}
+
+// replays are recordings read from files.
+type replays struct {
+	files []string
+
+	mu      sync.Mutex
+	modTime map[string]time.Time // of files read
+	recs    Recordings           // of every file
+}
+
+var errNotRecorded = errors.New("the output of this snippet is not recorded; record it by carousel record")
+
+// lookup returns the recording of the program.
+func (r *replays) lookup(body string) (*Recording, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	rec, ok := r.recs[recordingKey(body)]
+	if !ok {
+		return nil, errNotRecorded
+	}
+
+	return rec, nil
+}
+
+// load reads the files again if any of them is modified.
+func (r *replays) load() error {
+	modTime := make(map[string]time.Time)
+	modified := r.modTime == nil
+	for _, file := range r.files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+
+		modTime[file] = fi.ModTime()
+		if !fi.ModTime().Equal(r.modTime[file]) {
+			modified = true
+		}
+	}
+
+	if !modified && len(modTime) == len(r.modTime) {
+		return nil
+	}
+
+	recs := make(Recordings)
+	for file := range modTime {
+		fileRecs, err := ReadRecordings(file)
+		if err != nil {
+			return err
+		}
+
+		for key, rec := range fileRecs {
+			recs[key] = rec
+		}
+	}
+
+	r.recs, r.modTime = recs, modTime
+	return nil
+}
+
+// response returns the response of /compile replaying the program.
+func (r *replays) response(body string) compileResponse {
+	rec, err := r.lookup(body)
+	if err != nil {
+		return compileResponse{Errors: err.Error()}
+	}
+
+	resp := rec.response()
+	resp.Events = append([]event{{replayedLabel(rec), "system", 0}}, resp.Events...)
+
+	return resp
+}
+
+// replayedLabel tells the output is replayed.
+func replayedLabel(rec *Recording) string {
+	return fmt.Sprintf("Replaying output recorded at %s.\n", rec.Time.Format("2006-01-02 15:04"))
+}
+
+// replay sends the output recorded of the program with its timing, telling
+// it is replayed.
+func (p *process) replay(body string) error {
+	rec, err := p.pg.replays.lookup(body)
+	if err != nil {
+		return err
+	}
+
+	p.c.send(&Message{Id: p.id, Kind: "system", Body: replayedLabel(rec)})
+
+	if rec.Errors != "" {
+		p.c.send(&Message{Id: p.id, Kind: p.c.buildKind, Body: rec.Errors})
+	}
+
+	if rec.Started && p.c.startKind != "" {
+		p.c.send(&Message{Id: p.id, Kind: p.c.startKind})
+	}
+
+	wait := func(d time.Duration) bool {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return true
+		case <-p.cancel:
+			return false
+		}
+	}
+
+	for _, e := range rec.Events {
+		if !wait(e.Delay) {
+			return errKilled
+		}
+		p.c.send(&Message{Id: p.id, Kind: e.Kind, Body: e.Message})
+	}
+
+	if !wait(rec.EndDelay) {
+		return errKilled
+	}
+
+	if rec.End != "" {
+		return errors.New(rec.End)
+	}
+
+	return nil
+}
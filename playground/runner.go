@@ -0,0 +1,234 @@
+package playground
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// runner builds and runs programs of a language. A program is either built
+// into a binary to run, or run by an interpreter.
+type runner struct {
+	name         string
+	exts         []string // of snippets, and languages of fenced code as "." + language
+	interpreters []string // names of interpreters in shebangs
+	file         string   // name of the program file
+
+	build func(bin string, opt *Options) []string // command building file into bin
+	run   []string                                // command running file, if not built
+}
+
+// runners by name; go is always enabled, and the others must be enabled
+// by the config
+var runners = []*runner{
+	{
+		name:  "go",
+		exts:  []string{".go", ""},
+		file:  "prog.go", // errors of prog.go are highlighted in snippets by play.js
+		build: goBuild,
+	},
+	{
+		name:         "python",
+		exts:         []string{".py", ".python"},
+		interpreters: []string{"python", "python3"},
+		file:         "prog.py",
+		run:          []string{"python3"},
+	},
+	{
+		name:         "shell",
+		exts:         []string{".sh", ".bash", ".shell"},
+		interpreters: []string{"sh", "bash"},
+		file:         "prog.sh",
+		run:          []string{"sh"},
+	},
+	{
+		name:         "node",
+		exts:         []string{".js", ".javascript", ".node"},
+		interpreters: []string{"node", "nodejs"},
+		file:         "prog.js",
+		run:          []string{"node"},
+	},
+	{
+		name: "rust",
+		exts: []string{".rs", ".rust"},
+		file: "prog.rs",
+		build: func(bin string, opt *Options) []string {
+			return []string{"rustc", "-o", bin, "prog.rs"}
+		},
+	},
+}
+
+// goBuild returns the command building prog.go into bin.
+func goBuild(bin string, opt *Options) []string {
+	args := []string{"go", "build", "-tags", "OMIT"}
+	if opt != nil && opt.Race {
+		args = append(args, "-race")
+	}
+
+	return append(args, "-o", bin, "prog.go")
+}
+
+// Runners returns names of runners of languages.
+func Runners() []string {
+	var names []string
+	for _, r := range runners {
+		names = append(names, r.name)
+	}
+
+	return names
+}
+
+func runnerNamed(name string) *runner {
+	for _, r := range runners {
+		if r.name == name {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// command returns the name of the command the runner needs.
+func (r *runner) command() string {
+	if r.build != nil {
+		return r.build("prog", nil)[0]
+	}
+
+	return r.run[0]
+}
+
+// runnerOf selects the runner of the program by its shebang, or by the
+// extension of the snippet.
+func (pg *Playground) runnerOf(body string, opt *Options) (*runner, error) {
+	if path, args := shebang(body); path != "" {
+		name := filepath.Base(path)
+		if name == "env" && len(args) > 1 {
+			name = args[1]
+		}
+
+		for _, r := range runners {
+			for _, interpreter := range r.interpreters {
+				if interpreter == name {
+					return pg.enabled(r)
+				}
+			}
+		}
+
+		return nil, fmt.Errorf("no runner for scripts of %s", name)
+	}
+
+	ext := ""
+	if opt != nil {
+		ext = strings.ToLower(opt.Ext)
+	}
+
+	for _, r := range runners {
+		for _, e := range r.exts {
+			if e == ext {
+				return pg.enabled(r)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no runner for %s snippets", ext)
+}
+
+func (pg *Playground) enabled(r *runner) (*runner, error) {
+	if !pg.runners[r.name] {
+		return nil, fmt.Errorf("%s snippets are not run; add \"%s\" to runners of the config to run them", r.name, r.name)
+	}
+
+	return r, nil
+}
+
+// Compilers need more memory and files than programs of slides, so builds
+// get at least these limits.
+const (
+	minBuildMemory    = 2 << 30
+	minBuildOpenFiles = 1024
+)
+
+// buildLimits returns limits of building a program run within l.
+func buildLimits(l Limits) Limits {
+	if l.Memory > 0 && l.Memory < minBuildMemory {
+		l.Memory = minBuildMemory
+	}
+	if l.OpenFiles > 0 && l.OpenFiles < minBuildOpenFiles {
+		l.OpenFiles = minBuildOpenFiles
+	}
+
+	return l
+}
+
+// prepare returns the command running the program in dir by the runner,
+// building it before if needed. Programs with shebangs are run by the
+// interpreters in them, as exec does.
+func (p *process) prepare(r *runner, dir, body string, opt *Options) (*exec.Cmd, error) {
+	if err := ioutil.WriteFile(filepath.Join(dir, r.file), []byte(body), 0666); err != nil {
+		return nil, err
+	}
+
+	if path, args := shebang(body); path != "" {
+		return p.command(dir, path, append(args[1:], r.file)...), nil
+	}
+
+	if r.build == nil {
+		return p.command(dir, r.run[0], append(r.run[1:], r.file)...), nil
+	}
+
+	race := r.name == "go" && opt != nil && opt.Race
+	if race {
+		p.c.send(&Message{Id: p.id, Kind: "stderr", Body: "Running with race detector.\n"})
+	}
+
+	run := func(bin string) *exec.Cmd {
+		cmd := p.command(dir, bin)
+		if race {
+			cmd.Env = append(cmd.Env, "GOMAXPROCS=2")
+		}
+		return cmd
+	}
+
+	// binaries of Go are cached
+	key := ""
+	if cache := p.pg.cache; cache != nil && r.name == "go" {
+		var err error
+		if key, err = cache.key(body, race); err != nil {
+			p.pg.logger.Warnf("binary not cached: %v", err)
+		} else if bin := cache.lookup(key); bin != "" {
+			return run(bin), nil
+		} else if done := cache.begin(key); done != nil {
+			defer done()
+		}
+	}
+
+	bin := filepath.Join(dir, "prog")
+	if runtime.GOOS == "windows" {
+		bin += ".exe"
+	}
+
+	build := &output{p: p, kind: p.c.buildKind}
+	defer build.flush()
+
+	args := r.build(bin, opt)
+	cmd := p.command(dir, args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = build, build
+	limit(cmd, buildLimits(p.limits))
+	if err := p.exec(cmd); err != nil {
+		return nil, err
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		if err := p.pg.cache.store(key, bin); err != nil {
+			p.pg.logger.Warnf("binary not cached: %v", err)
+		}
+	}
+
+	return run(bin), nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"carousel/playground"
+	"carousel/renderer"
+	"flag"
+	"fmt"
+	"os"
+)
+
+var recordCommand = &command{
+	name:  "record",
+	args:  "filepath|directory...",
+	short: "record output of .play snippets to replay them",
+	long: `
+Record runs every .play snippet of slides within playLimits of the config,
+as serve -P does, and stores its output of stdout and stderr with timing
+next to the slides: talk.play.json for talk.slide. Serve -replay streams
+the recorded output instead of running snippets, on machines which can't
+run them.
+
+The file is written anew each time. Record exits with 3 if any snippet
+fails to build or exits with an error, which is recorded as well.`,
+	flags: recordFlags,
+	run:   runRecord,
+}
+
+func recordFlags(fs *flag.FlagSet) {
+	inputFlags(fs)
+	logFlags(fs)
+}
+
+func runRecord(cmd *command, fs *flag.FlagSet) int {
+	if fs.NArg() < 1 {
+		return cmd.usageError("no slides given")
+	}
+
+	if err := loadConfig(fs, fs.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	cleanup := setupLogging()
+	defer cleanup()
+
+	if err := checkInput(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	files, err := deckFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	pg, err := playground.New(playLimits(cfg.PlayLimits), cfg.Runners, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	failed, problems := false, false
+
+	for _, file := range files {
+		doc, _, err := renderer.Parse(file, formatOf(file, cfg.Format), cfg.Encoding)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to record '%s': %v\n", file, err)
+			failed = true
+			continue
+		}
+
+		snippets := renderer.PlaySnippets(doc)
+		if len(snippets) == 0 {
+			continue
+		}
+
+		recs := make(playground.Recordings)
+		for _, s := range snippets {
+			rec := pg.Record(s.Body, &playground.Options{Ext: s.Ext})
+			recs.Add(s.Body, rec)
+
+			if rec.End != "" {
+				fmt.Printf("%s: %s: %s\n", file, s.File, rec.End)
+				problems = true
+			}
+		}
+
+		out := playground.RecordingsFile(file)
+		if err := recs.Write(out); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to record '%s': %v\n", file, err)
+			failed = true
+			continue
+		}
+
+		fmt.Printf("%s: %d snippets recorded in %s\n", file, len(recs), out)
+	}
+
+	switch {
+	case failed:
+		return exitFailure
+	case problems:
+		return exitProblems
+	}
+
+	return exitOK
+}
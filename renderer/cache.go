@@ -0,0 +1,111 @@
+package renderer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Rendered is an output kept to be served again until its source changes.
+type Rendered struct {
+	Body    []byte
+	Gzipped []byte    // Body compressed by gzip
+	ETag    string    // quoted hash of what Body is made from
+	ModTime time.Time // of the latest file Body is made from
+}
+
+// NewRendered keeps body with its gzipped copy. sum identifies what body is
+// made from.
+func NewRendered(body []byte, sum []byte, modTime time.Time) (*Rendered, error) {
+	var buf bytes.Buffer
+
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return &Rendered{
+		Body:    body,
+		Gzipped: buf.Bytes(),
+		ETag:    fmt.Sprintf(`"%x"`, sum),
+		ModTime: modTime,
+	}, nil
+}
+
+// renderCache is the output of a version of slides, which is rendered at
+// the first request. Versions of the same contents share one.
+type renderCache struct {
+	sum     []byte
+	modTime time.Time
+
+	once     sync.Once
+	rendered *Rendered
+	err      error
+}
+
+func (c *renderCache) get(rendFun renderFunc, failure error) (*Rendered, error) {
+	c.once.Do(func() {
+		var buf bytes.Buffer
+		if c.err = rendFun(&buf, failure); c.err != nil {
+			return
+		}
+		c.rendered, c.err = NewRendered(buf.Bytes(), c.sum, c.modTime)
+	})
+
+	return c.rendered, c.err
+}
+
+// newRenderCache returns the cache of the output made from files and the
+// other things written into h, reusing last if they are the same as its.
+func newRenderCache(last *renderCache, h hash.Hash, files []string) *renderCache {
+	var modTime time.Time
+
+	for _, fname := range files {
+		fmt.Fprintf(h, "%s\x00", fname)
+
+		f, err := os.Open(fname)
+		if err != nil {
+			// missing dependencies count as well
+			fmt.Fprintf(h, "%v\x00", err)
+			continue
+		}
+		if fi, err := f.Stat(); err == nil && fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+		io.Copy(h, f)
+		f.Close()
+	}
+
+	sum := h.Sum(nil)
+	if last != nil && bytes.Equal(last.sum, sum) {
+		return last
+	}
+
+	return &renderCache{sum: sum, modTime: modTime}
+}
+
+// outputs of templates built in the binary may differ after restarting
+var startTime = time.Now()
+
+// hashOf returns a hash into which strings are written.
+func hashOf(ss ...string) hash.Hash {
+	h := sha1.New()
+	fmt.Fprintf(h, "%d\x00", startTime.UnixNano())
+	for _, s := range ss {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+
+	return h
+}
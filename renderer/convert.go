@@ -0,0 +1,419 @@
+package renderer
+
+import (
+	"bufio"
+	"bytes"
+	"code.google.com/p/go.tools/present"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SnippetSaver saves code of a snippet under a name made from n and ext,
+// and returns the path by which slides refer it. It is used when slides
+// are converted into the present format, which can't have code inline.
+type SnippetSaver func(n int, ext string, code []byte) (string, error)
+
+// Convert writes the slides of filename in the format to. Warnings are
+// about what the format to can't express, which is dropped. Code of
+// snippets is saved by save, or becomes indented blocks if save is nil.
+func Convert(w io.Writer, filename, from, to, encoding string, save SnippetSaver) (warnings []string, err error) {
+	parse, err := parserOf(from)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := readSource(filename, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, _, err := parseSource(b, filename, parse)
+	if err != nil {
+		return nil, err
+	}
+
+	cv := &converter{
+		w:          bufio.NewWriter(w),
+		directives: readDirectives(b),
+		alts:       ImageAlts(b),
+		save:       save,
+	}
+
+	switch to {
+	case FormatMarkdown:
+		cv.writeMarkdown(doc)
+	case FormatPresent:
+		cv.writePresent(doc)
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", to)
+	}
+
+	if cv.err != nil {
+		return cv.warnings, cv.err
+	}
+
+	return cv.warnings, cv.w.Flush()
+}
+
+type converter struct {
+	w          *bufio.Writer
+	directives map[string]string
+	alts       map[string]string
+	save       SnippetSaver
+	snippets   int
+
+	warnings []string
+	err      error // of saving snippets
+}
+
+func (cv *converter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(cv.w, format, args...)
+}
+
+func (cv *converter) warnf(format string, args ...interface{}) {
+	cv.warnings = append(cv.warnings, fmt.Sprintf(format, args...))
+}
+
+// writeDirectives writes directives of the header in the order of names.
+func (cv *converter) writeDirectives() {
+	var names []string
+	for name := range cv.directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cv.printf("#%s: %s\n", name, cv.directives[name])
+	}
+}
+
+// writeHeader writes lines after the title, which are the same in both
+// formats.
+func (cv *converter) writeHeader(doc *present.Doc) {
+	if doc.Subtitle != "" {
+		cv.printf("%s\n", doc.Subtitle)
+	}
+	if !doc.Time.IsZero() {
+		cv.printf("%s\n", doc.Time.Format("15:04 2 Jan 2006"))
+	}
+	if len(doc.Tags) > 0 {
+		cv.printf("Tags: %s\n", strings.Join(doc.Tags, ", "))
+	}
+	cv.writeDirectives()
+}
+
+// writeAuthors writes authors separated by blank lines. Links are written
+// as the text they are made from in both formats.
+func (cv *converter) writeAuthors(authors []present.Author) {
+	for _, a := range authors {
+		cv.printf("\n")
+
+		for _, e := range a.Elem {
+			switch t := e.(type) {
+			case present.Text:
+				for _, l := range t.Lines {
+					cv.printf("%s\n", l)
+				}
+			case present.Link:
+				cv.printf("%s\n", authorText(t))
+			}
+		}
+	}
+}
+
+func authorText(l present.Link) string {
+	switch {
+	case l.URL.Scheme == "mailto":
+		return l.URL.Opaque
+	case l.URL.Host == "twitter.com":
+		return "@" + strings.TrimPrefix(l.URL.Path, "/")
+	}
+
+	return l.URL.String()
+}
+
+// Markdown
+
+func (cv *converter) writeMarkdown(doc *present.Doc) {
+	cv.printf("# %s\n", doc.Title)
+	cv.writeHeader(doc)
+	cv.writeAuthors(doc.Authors)
+
+	for _, s := range doc.Sections {
+		cv.printf("\n## %s\n", s.Title)
+		cv.writeMarkdownElems(s.Elem, 3)
+	}
+}
+
+func (cv *converter) writeMarkdownElems(elems []present.Elem, level int) {
+	for _, e := range elems {
+		cv.printf("\n")
+
+		switch t := e.(type) {
+		case present.Section:
+			cv.printf("%s %s\n", strings.Repeat("#", level), t.Title)
+			cv.writeMarkdownElems(t.Elem, level+1)
+
+		case present.Text:
+			if t.Pre {
+				cv.writeIndented("    ", strings.Join(t.Lines, "\n"))
+				break
+			}
+			for _, l := range t.Lines {
+				cv.printf("%s\n", markdownOf(l))
+			}
+
+		case present.List:
+			for _, b := range t.Bullet {
+				cv.printf("- %s\n", markdownOf(b))
+			}
+
+		case present.Code:
+			info := strings.TrimPrefix(t.Ext, ".")
+			if t.Play {
+				info = strings.TrimSpace(info + " play")
+			}
+			cv.printf("```%s\n%s```\n", info, ensureNewline(string(t.Raw)))
+
+		case present.Image:
+			if t.Width != 0 || t.Height != 0 {
+				cv.warnf("size of image %s is dropped", t.URL)
+			}
+			cv.printf("![%s](%s)\n", cv.alts[t.URL], t.URL)
+
+		case present.Link:
+			cv.printf("[%s](%s)\n", linkLabel(t), t.URL)
+
+		case present.Iframe:
+			cv.warnf("iframe %s becomes a link", t.URL)
+			cv.printf("<%s>\n", t.URL)
+
+		case present.Caption:
+			cv.printf("*%s*\n", t.Text)
+
+		case present.HTML:
+			cv.warnf("HTML is dropped")
+
+		default:
+			cv.warnf("%s is dropped", e.TemplateName())
+		}
+	}
+}
+
+func linkLabel(l present.Link) string {
+	if l.Label != "" {
+		return l.Label
+	}
+
+	return l.URL.String()
+}
+
+var (
+	htmlTagRE    = regexp.MustCompile(`<[^>]*>`)
+	htmlBoldRE   = regexp.MustCompile(`<b>(.*?)</b>`)
+	htmlItalicRE = regexp.MustCompile(`<i>(.*?)</i>`)
+	htmlCodeRE   = regexp.MustCompile(`<code>(.*?)</code>`)
+	htmlLinkRE   = regexp.MustCompile(`<a href="([^"]*)"[^>]*>(.*?)</a>`)
+)
+
+// markdownOf converts inline markups of the present format into Markdown.
+// It goes through the HTML made by present, so that markups are read just
+// as present does. Text but code is escaped, not to be read as markups.
+func markdownOf(s string) string {
+	h := escapeMarkdownText(string(present.Style(s)))
+
+	h = htmlCodeRE.ReplaceAllString(h, "`$1`")
+	h = htmlBoldRE.ReplaceAllString(h, "**$1**")
+	h = htmlItalicRE.ReplaceAllString(h, "*$1*")
+	h = htmlLinkRE.ReplaceAllString(h, "[$2]($1)")
+
+	return escapeLineStart(html.UnescapeString(h))
+}
+
+// escapeMarkdownText escapes text between tags of the HTML but that of
+// code, which Markdown takes as it is.
+func escapeMarkdownText(h string) string {
+	var b bytes.Buffer
+
+	inCode := false
+	last := 0
+	for _, loc := range htmlTagRE.FindAllStringIndex(h, -1) {
+		text := h[last:loc[0]]
+		if !inCode {
+			text = escapeMarkdown(text)
+		}
+		b.WriteString(text)
+
+		tag := h[loc[0]:loc[1]]
+		switch tag {
+		case "<code>":
+			inCode = true
+		case "</code>":
+			inCode = false
+		}
+		b.WriteString(tag)
+
+		last = loc[1]
+	}
+	b.WriteString(escapeMarkdown(h[last:]))
+
+	return b.String()
+}
+
+// markdownEscaper escapes characters starting inline markups of Markdown
+// in text escaped for HTML.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"*", `\*`,
+	"_", `\_`,
+	"[", `\[`,
+	"&lt;", `\&lt;`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+var mdBlockStartRE = regexp.MustCompile(`^(?:[#>+-]|[0-9]+[.)])`)
+
+// escapeLineStart escapes the start of a line which would start a heading,
+// a quote or a list item.
+func escapeLineStart(s string) string {
+	if loc := mdBlockStartRE.FindStringIndex(s); loc != nil {
+		return s[:loc[1]-1] + `\` + s[loc[1]-1:]
+	}
+
+	return s
+}
+
+// writeIndented writes lines of text indented, leaving empty lines empty.
+func (cv *converter) writeIndented(indent, text string) {
+	for _, l := range strings.Split(text, "\n") {
+		if l == "" {
+			cv.printf("\n")
+			continue
+		}
+		cv.printf("%s%s\n", indent, l)
+	}
+}
+
+func ensureNewline(s string) string {
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+
+	return s
+}
+
+// Present
+
+func (cv *converter) writePresent(doc *present.Doc) {
+	cv.printf("%s\n", doc.Title)
+	cv.writeHeader(doc)
+	cv.writeAuthors(doc.Authors)
+
+	for _, s := range doc.Sections {
+		cv.printf("\n* %s\n", s.Title)
+		cv.writePresentElems(s.Elem, 2)
+	}
+}
+
+func (cv *converter) writePresentElems(elems []present.Elem, level int) {
+	for _, e := range elems {
+		cv.printf("\n")
+
+		switch t := e.(type) {
+		case present.Section:
+			cv.printf("%s %s\n", strings.Repeat("*", level), t.Title)
+			cv.writePresentElems(t.Elem, level+1)
+
+		case present.Text:
+			if t.Pre {
+				cv.writeIndented("  ", strings.Join(t.Lines, "\n"))
+				break
+			}
+			for _, l := range t.Lines {
+				// a period at the start would make a command
+				if strings.HasPrefix(l, ".") {
+					l = `\` + l
+				}
+				cv.printf("%s\n", l)
+			}
+
+		case present.List:
+			for _, b := range t.Bullet {
+				cv.printf("- %s\n", b)
+			}
+
+		case present.Code:
+			cv.writePresentCode(t)
+
+		case present.Image:
+			cv.printf(".image %s", t.URL)
+			if t.Width != 0 || t.Height != 0 {
+				cv.printf(" %s %s", sizeArg(t.Height), sizeArg(t.Width))
+			}
+			cv.printf("\n")
+			if alt := cv.alts[t.URL]; alt != "" {
+				cv.printf("#alt: %s\n", alt)
+			}
+
+		case present.Link:
+			cv.printf(".link %s %s\n", t.URL, t.Label)
+
+		case present.Iframe:
+			cv.printf(".iframe %s %d %d\n", t.URL, t.Height, t.Width)
+
+		case present.Caption:
+			cv.printf(".caption %s\n", t.Text)
+
+		case present.HTML:
+			cv.warnf("HTML is dropped")
+
+		default:
+			cv.warnf("%s is dropped", e.TemplateName())
+		}
+	}
+}
+
+// writePresentCode writes a snippet as .code or .play of the file saved,
+// or as an indented block if it can't be saved.
+func (cv *converter) writePresentCode(c present.Code) {
+	if cv.save == nil {
+		if c.Play {
+			cv.warnf("snippet %d is not runnable without its file", cv.snippets+1)
+		}
+		cv.snippets++
+
+		cv.writeIndented("  ", strings.TrimRight(string(c.Raw), "\n"))
+		return
+	}
+
+	cv.snippets++
+	path, err := cv.save(cv.snippets, c.Ext, c.Raw)
+	if err != nil {
+		if cv.err == nil {
+			cv.err = err
+		}
+		return
+	}
+
+	cmd := "code"
+	if c.Play {
+		cmd = "play"
+	}
+	cv.printf(".%s %s\n", cmd, path)
+}
+
+func sizeArg(n int) string {
+	if n == 0 {
+		return "_"
+	}
+
+	return fmt.Sprint(n)
+}
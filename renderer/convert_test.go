@@ -0,0 +1,164 @@
+package renderer
+
+import (
+	"bytes"
+	"code.google.com/p/go.tools/present"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// convertFile converts the slides into a file of dir, saving snippets in
+// dir, and returns the name of the file.
+func convertFile(t *testing.T, filename, from, to, dir string) string {
+	save := func(n int, ext string, code []byte) (string, error) {
+		name := fmt.Sprintf("snippet%d%s", n, ext)
+		return name, ioutil.WriteFile(filepath.Join(dir, name), code, 0644)
+	}
+
+	var buf bytes.Buffer
+	if _, err := Convert(&buf, filename, from, to, EncodingAuto, save); err != nil {
+		t.Fatalf("%s: converting to %s: %v", filename, to, err)
+	}
+
+	out := filepath.Join(dir, "converted"+extOf(to))
+	if err := ioutil.WriteFile(out, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func extOf(format string) string {
+	if format == FormatMarkdown {
+		return ".md"
+	}
+
+	return ".slide"
+}
+
+// parseFile parses the slides, and leaves out of the document what the
+// other format can't express or writes differently.
+func parseFile(t *testing.T, filename, format string) *present.Doc {
+	parse, err := parserOf(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := readSource(filename, EncodingAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, _, err := parseSource(b, filename, parse)
+	if err != nil {
+		t.Fatalf("%s: %v", filename, err)
+	}
+
+	// runs of blank lines make empty authors in the present format
+	var authors []present.Author
+	for _, a := range doc.Authors {
+		if len(a.Elem) > 0 && !reflect.DeepEqual(a.Elem, []present.Elem{present.Text{Lines: []string{""}}}) {
+			authors = append(authors, a)
+		}
+	}
+	doc.Authors = authors
+
+	for i := range doc.Sections {
+		doc.Sections[i].Elem = comparableElems(doc.Sections[i].Elem)
+	}
+
+	return doc
+}
+
+func comparableElems(elems []present.Elem) []present.Elem {
+	var out []present.Elem
+
+	for _, e := range elems {
+		switch t := e.(type) {
+		case present.Section:
+			t.Elem = comparableElems(t.Elem)
+			e = t
+		case present.Code:
+			// HTML of code is made from the file or the block
+			e = present.Code{Ext: t.Ext, Play: t.Play, Raw: t.Raw}
+		case present.Image:
+			// sizes are dropped in Markdown
+			e = present.Image{URL: t.URL}
+		case present.Link:
+			// links are inline in Markdown
+			e = present.Text{Lines: []string{"[[" + t.URL.String() + "][" + t.Label + "]]"}}
+		}
+		out = append(out, e)
+	}
+
+	return out
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	for _, file := range []string{
+		"../samples/helloworld.slide",
+		"../samples/go1.slide",
+		"../samples/markdown.md",
+	} {
+		dir, err := ioutil.TempDir("", "carousel-convert")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		from := FormatOf(file)
+		to := FormatMarkdown
+		if from == FormatMarkdown {
+			to = FormatPresent
+		}
+
+		converted := convertFile(t, file, from, to, dir)
+		back := convertFile(t, converted, to, from, dir)
+
+		want, got := parseFile(t, file, from), parseFile(t, back, from)
+		if !reflect.DeepEqual(want, got) {
+			b, _ := ioutil.ReadFile(back)
+			t.Errorf("%s: converted back to %s differs:\n%s", file, from, b)
+		}
+
+		os.RemoveAll(dir)
+	}
+}
+
+func TestMarkdownOf(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"*bold* and _italic_", "**bold** and *italic*"},
+		{"`code` of a_b", "`code` of a\\_b"},
+		{"[[http://golang.org][Go]]", "[Go](http://golang.org)"},
+		{"[[http://golang.org/a_b]]", `[golang.org/a\_b](http://golang.org/a_b)`},
+		{"[[a_b.html]]", `[a\_b.html](a_b.html)`},
+		{"2*3 and a_b_c", `2\*3 and a\_b\_c`},
+		{"a [bracket] and <tag>", `a \[bracket] and \<tag>`},
+		{`a \ backslash`, `a \\ backslash`},
+		{"a ` backtick", "a \\` backtick"},
+		{"# not a heading", `\# not a heading`},
+		{"- not an item", `\- not an item`},
+		{"10. not an item", `10\. not an item`},
+		{"> not a quote", `\> not a quote`},
+		{"C# and 1.5", "C# and 1.5"},
+	}
+
+	for _, test := range tests {
+		got := markdownOf(test.text)
+		if got != test.want {
+			t.Errorf("%q: got %q; want %q", test.text, got, test.want)
+		}
+
+		// reads back as the same text
+		if back := markdownInline(got); present.Style(back) != present.Style(test.text) {
+			t.Errorf("%q: read back as %q", test.text, back)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/suapapa/go_hangul/encoding/cp949"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// EncodingAuto detects the encoding of slides by its BOM, and otherwise
+// takes it as UTF-8, falling back to CP949 for legacy Korean slides.
+const EncodingAuto = "auto"
+
+// decodeFunc converts text in an encoding into UTF-8.
+type decodeFunc func(b []byte) ([]byte, error)
+
+var decoders = map[string]decodeFunc{
+	"utf-8":     decodeUTF8,
+	"utf-16le":  decoderOf(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)),
+	"utf-16be":  decoderOf(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)),
+	"cp949":     decodeCP949,
+	"euc-kr":    decodeCP949, // CP949 is a superset of EUC-KR
+	"euc-jp":    decoderOf(japanese.EUCJP),
+	"shift_jis": decoderOf(japanese.ShiftJIS),
+	"gbk":       decoderOf(simplifiedchinese.GBK),
+	"gb18030":   decoderOf(simplifiedchinese.GB18030),
+	"latin-1":   decoderOf(charmap.ISO8859_1),
+}
+
+var encodingAliases = map[string]string{
+	"utf8":       "utf-8",
+	"sjis":       "shift_jis",
+	"shift-jis":  "shift_jis",
+	"gb2312":     "gbk",
+	"latin1":     "latin-1",
+	"iso-8859-1": "latin-1",
+}
+
+var boms = []struct {
+	bom      []byte
+	encoding string
+}{
+	{[]byte{0xef, 0xbb, 0xbf}, "utf-8"},
+	{[]byte{0xff, 0xfe}, "utf-16le"},
+	{[]byte{0xfe, 0xff}, "utf-16be"},
+}
+
+// Encodings returns names of supported encodings.
+func Encodings() []string {
+	var names []string
+	for name := range decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// CheckEncoding returns an error if the encoding is not supported.
+func CheckEncoding(name string) error {
+	_, err := canonicalEncoding(name)
+	return err
+}
+
+func canonicalEncoding(name string) (string, error) {
+	name = strings.ToLower(name)
+	if alias, ok := encodingAliases[name]; ok {
+		name = alias
+	}
+
+	if _, ok := decoders[name]; ok || name == EncodingAuto {
+		return name, nil
+	}
+
+	return "", fmt.Errorf("unknown encoding '%s'; supported are %s and %s", name, EncodingAuto, strings.Join(Encodings(), ", "))
+}
+
+// readSource reads the slides and converts it into UTF-8 without BOM.
+// The #encoding directive of the slides overrides the encoding given.
+func readSource(filename, encoding string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, fmt.Errorf("'%s' is empty", filename)
+	}
+
+	b, err = decode(b, encoding)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode '%s': %v", filename, err)
+	}
+
+	return b, nil
+}
+
+func decode(b []byte, encoding string) ([]byte, error) {
+	// BOM tells the encoding for sure
+	for _, m := range boms {
+		if bytes.HasPrefix(b, m.bom) {
+			return decoders[m.encoding](b[len(m.bom):])
+		}
+	}
+
+	// directives are in ASCII, which every supported encoding but UTF-16
+	// keeps as is
+	if name, ok := readDirectives(b)["encoding"]; ok {
+		encoding = name
+	}
+
+	encoding, err := canonicalEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	if encoding != EncodingAuto {
+		return decoders[encoding](b)
+	}
+
+	if utf8.Valid(b) {
+		return b, nil
+	}
+
+	// it may be cp949, so convert it to utf-8
+	if d, err := decodeCP949(b); err == nil {
+		return d, nil
+	}
+
+	return nil, fmt.Errorf("invalid UTF-8 at line %d; give the encoding by -encoding or the #encoding directive", invalidLine(b))
+}
+
+func decodeUTF8(b []byte) ([]byte, error) {
+	if !utf8.Valid(b) {
+		return nil, fmt.Errorf("invalid UTF-8 at line %d", invalidLine(b))
+	}
+
+	return b, nil
+}
+
+// decodeCP949 converts CP949, whose decoder replaces invalid characters by
+// U+FFFD silently and panics on a lead byte at the end.
+func decodeCP949(b []byte) ([]byte, error) {
+	// characters but ASCII are of two bytes, and trail bytes are never '\n'
+	n := 0
+	for n < len(b) {
+		if b[n] < 0x80 {
+			n++
+		} else {
+			n += 2
+		}
+	}
+	if n > len(b) {
+		return nil, fmt.Errorf("invalid CP949 at line %d", bytes.Count(b, []byte("\n"))+1)
+	}
+
+	d, err := cp949.From(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// CP949 lacks U+FFFD, so it is always of invalid characters
+	line := 1
+	for rest := d; len(rest) > 0; {
+		r, size := utf8.DecodeRune(rest)
+		if r == utf8.RuneError {
+			return nil, fmt.Errorf("invalid CP949 at line %d", line)
+		}
+		if r == '\n' {
+			line++
+		}
+		rest = rest[size:]
+	}
+
+	return d, nil
+}
+
+// decoderOf returns a decoder which fails on bytes invalid in the encoding,
+// instead of replacing them silently.
+func decoderOf(enc encoding.Encoding) decodeFunc {
+	return func(b []byte) ([]byte, error) {
+		d, err := enc.NewDecoder().Bytes(b)
+		if err != nil {
+			return nil, err
+		}
+
+		// U+FFFD may be in the source itself, as in UTF-16 or GB18030
+		if bytes.IndexRune(d, utf8.RuneError) >= 0 {
+			if line := replacedLine(enc, b); line > 0 {
+				return nil, fmt.Errorf("invalid %s at line %d", enc, line)
+			}
+		}
+
+		return d, nil
+	}
+}
+
+var replacementChar = []byte(string(utf8.RuneError))
+
+// replacedLine returns the line number of the first bytes which the
+// decoder of the encoding replaces by U+FFFD, or 0 if there are none.
+func replacedLine(enc encoding.Encoding, b []byte) int {
+	dec := enc.NewDecoder()
+	// nil if the encoding lacks U+FFFD
+	encoded, _ := enc.NewEncoder().Bytes(replacementChar)
+
+	var buf [utf8.UTFMax]byte
+	line := 1
+
+	for len(b) > 0 {
+		// a character at a time: U+FFFD alone fills 3 bytes, and a
+		// character of 4 bytes can't be it
+		nDst, nSrc, err := dec.Transform(buf[:3], b, true)
+		if err == transform.ErrShortDst && nDst == 0 {
+			nDst, nSrc, err = dec.Transform(buf[:], b, true)
+		}
+		if err != nil && err != transform.ErrShortDst || nSrc == 0 {
+			return line
+		}
+
+		if bytes.Equal(buf[:nDst], replacementChar) && !bytes.Equal(b[:nSrc], encoded) {
+			return line
+		}
+
+		line += bytes.Count(buf[:nDst], []byte("\n"))
+		b = b[nSrc:]
+	}
+
+	return 0
+}
+
+// invalidLine returns the line number of the first invalid UTF-8 sequence.
+func invalidLine(b []byte) int {
+	line := 1
+
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size <= 1 {
+			break
+		}
+		if r == '\n' {
+			line++
+		}
+		b = b[size:]
+	}
+
+	return line
+}
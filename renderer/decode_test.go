@@ -0,0 +1,145 @@
+package renderer
+
+import (
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	utf16le = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	utf16be = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+)
+
+func encode(t *testing.T, enc encoding.Encoding, s string) string {
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		src      string
+		want     string
+	}{
+		{"utf-8", "utf-8", "Title 안녕\n", "Title 안녕\n"},
+		{"utf-8 by alias", "UTF8", "Title 안녕\n", "Title 안녕\n"},
+		{"utf-16le", "utf-16le", encode(t, utf16le, "Title 안녕\n"), "Title 안녕\n"},
+		{"utf-16be", "utf-16be", encode(t, utf16be, "Title 안녕\n"), "Title 안녕\n"},
+		{"cp949", "cp949", "Title \xbe\xc8\xb3\xe7\n", "Title 안녕\n"},
+		{"euc-kr", "euc-kr", "Title \xbe\xc8\xb3\xe7\n", "Title 안녕\n"},
+		{"euc-jp", "euc-jp", "Title \xc6\xfc\xcb\xdc\n", "Title 日本\n"},
+		{"shift_jis", "shift_jis", "Title \x93\xfa\x96\x7b\n", "Title 日本\n"},
+		{"shift_jis by alias", "sjis", "Title \x93\xfa\x96\x7b\n", "Title 日本\n"},
+		{"gbk", "gbk", "Title \xd6\xd0\xce\xc4\n", "Title 中文\n"},
+		{"gb18030", "gb18030", "Title \xd6\xd0\xce\xc4\n", "Title 中文\n"},
+		{"latin-1", "latin-1", "Title caf\xe9\n", "Title café\n"},
+
+		// replacement characters written in the source are kept
+		{"U+FFFD of utf-16le", "utf-16le", encode(t, utf16le, "Title �\n"), "Title �\n"},
+		{"U+FFFD of gb18030", "gb18030", encode(t, simplifiedchinese.GB18030, "Title �\n"), "Title �\n"},
+
+		{"auto of utf-8", EncodingAuto, "Title 안녕\n", "Title 안녕\n"},
+		{"auto of cp949", EncodingAuto, "Title \xbe\xc8\xb3\xe7\n", "Title 안녕\n"},
+		{"directive", EncodingAuto, "#encoding: euc-jp\nTitle \xc6\xfc\xcb\xdc\n", "#encoding: euc-jp\nTitle 日本\n"},
+		{"directive over the flag", "latin-1", "#encoding: sjis\nTitle \x93\xfa\x96\x7b\n", "#encoding: sjis\nTitle 日本\n"},
+
+		// BOMs tell the encoding over the flag and directives
+		{"utf-8 BOM", "latin-1", "\xef\xbb\xbfTitle 안녕\n", "Title 안녕\n"},
+		{"utf-16le BOM", "cp949", "\xff\xfe" + encode(t, utf16le, "Title 안녕\n"), "Title 안녕\n"},
+		{"utf-16be BOM", EncodingAuto, "\xfe\xff" + encode(t, utf16be, "#encoding: latin-1\nTitle 안녕\n"), "#encoding: latin-1\nTitle 안녕\n"},
+	}
+
+	for _, test := range tests {
+		got, err := decode([]byte(test.src), test.encoding)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if string(got) != test.want {
+			t.Errorf("%s: got %q; want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		src      string
+		wantErr  string
+	}{
+		{"unknown encoding", "ebcdic", "Title\n", "unknown encoding 'ebcdic'"},
+		{"unknown encoding of directive", EncodingAuto, "#encoding: koi8-r\nTitle\n", "unknown encoding 'koi8-r'"},
+		{"utf-8", "utf-8", "Title\n\nText \xff\n", "invalid UTF-8 at line 3"},
+		{"auto", EncodingAuto, "Title\nText \xff\xff\n", "invalid UTF-8 at line 2"},
+		{"utf-16le", "utf-16le", encode(t, utf16le, "Title\nText ") + "\x00\xd8" + encode(t, utf16le, "\n"), "at line 2"},
+		{"auto ending in a lead byte", EncodingAuto, "Title\nText\n\xbe", "invalid UTF-8 at line 3"},
+		{"cp949", "cp949", "Title\n\xbe\xc8\n\xff\xff\n", "invalid CP949 at line 3"},
+		{"cp949 ending in a lead byte", "euc-kr", "Title\n\xbe\xc8\n\xbe", "invalid CP949 at line 3"},
+		{"shift_jis", "shift_jis", "Title\n\n\x93\xfa\n\x82\n", "at line 4"},
+		{"euc-jp", "euc-jp", "Title\n\xc6\xfc\xff\xff\n", "at line 2"},
+		{"gbk", "gbk", "Title\n\n\xd6\xd0\n\xff\n", "at line 4"},
+		{"utf-16le BOM", "utf-8", "\xff\xfe" + encode(t, utf16le, "Title\n") + "\x00\xdc", "at line 2"},
+	}
+
+	for _, test := range tests {
+		_, err := decode([]byte(test.src), test.encoding)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: error %v; want one of %s", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestReplacedLine(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  encoding.Encoding
+		src  string
+		want int
+	}{
+		{"none", japanese.ShiftJIS, "Title\n\x93\xfa\x96\x7b\n", 0},
+		{"first line", japanese.ShiftJIS, "\x82\nTitle\n", 1},
+		{"after characters of two bytes", japanese.ShiftJIS, "\x93\xfa\n\x96\x7b\n\x82", 3},
+		{"U+FFFD in the source", simplifiedchinese.GB18030, encode(t, simplifiedchinese.GB18030, "a\n�\n"), 0},
+		{"U+FFFD in the source and a bad byte", simplifiedchinese.GB18030, encode(t, simplifiedchinese.GB18030, "a\n�\n") + "\xff", 3},
+		{"every byte of latin-1", charmap.ISO8859_1, "\x00\xff\n\x80", 0},
+		{"lone surrogate", utf16be, encode(t, utf16be, "a\nb\n") + "\xdc\x00", 3},
+	}
+
+	for _, test := range tests {
+		if got := replacedLine(test.enc, []byte(test.src)); got != test.want {
+			t.Errorf("%s: line %d; want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReadSourceEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "carousel-decode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "empty.slide")
+	if err := ioutil.WriteFile(filename, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readSource(filename, EncodingAuto); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("error %v; want one of an empty file", err)
+	}
+}
@@ -0,0 +1,85 @@
+package renderer
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Directives are settings of carousel written in the header of slides as
+// comment lines, such as
+//
+//	#theme: dark
+//
+// present ignores lines starting with '#', so slides having directives
+// are still valid for other present tools. Likewise, an #alt directive
+// right after an .image line gives the alternative text of the image.
+var directiveRE = regexp.MustCompile(`^#([a-z][a-z0-9-]*):\s*(.*?)\s*$`)
+
+func isDirective(text string) bool {
+	return directiveRE.MatchString(text)
+}
+
+// readDirectives returns directives found before the first slide.
+func readDirectives(b []byte) map[string]string {
+	directives := make(map[string]string)
+	title := ""
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		text := strings.TrimRight(s.Text(), "\r")
+
+		if m := directiveRE.FindStringSubmatch(text); m != nil {
+			directives[m[1]] = m[2]
+			continue
+		}
+
+		// slides start with "* " in the present format, and with headings
+		// other than the title in Markdown
+		if strings.HasPrefix(text, "* ") || strings.HasPrefix(text, "## ") {
+			break
+		}
+
+		// the title is the first line of the header; it is a heading in
+		// Markdown, and lines of '#' are comments in the present format
+		if title == "" {
+			title = strings.TrimSpace(text)
+			continue
+		}
+		if strings.HasPrefix(title, "# ") && strings.HasPrefix(text, "# ") {
+			break
+		}
+	}
+
+	return directives
+}
+
+// ImageAlts returns alternative texts of images keyed by their URL, given
+// by #alt directives in the present format and by ![alt](url) in Markdown.
+func ImageAlts(b []byte) map[string]string {
+	alts := make(map[string]string)
+	image := ""
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		text := strings.TrimSpace(s.Text())
+
+		if m := directiveRE.FindStringSubmatch(text); m != nil && m[1] == "alt" && image != "" {
+			if _, ok := alts[image]; !ok {
+				alts[image] = m[2]
+			}
+		}
+		image = ""
+
+		if f := strings.Fields(text); len(f) > 1 && f[0] == ".image" {
+			image = f[1]
+		} else if m := mdImageRE.FindStringSubmatch(text); m != nil && m[1] != "" {
+			if _, ok := alts[m[2]]; !ok {
+				alts[m[2]] = m[1]
+			}
+		}
+	}
+
+	return alts
+}
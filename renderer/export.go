@@ -0,0 +1,173 @@
+package renderer
+
+import (
+	"carousel/static"
+	"carousel/theme"
+	"code.google.com/p/go.tools/present"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+)
+
+// inlineAssets has static contents embedded into an exported page.
+type inlineAssets struct {
+	SlidesJS template.JS
+	StyleCSS template.CSS
+	PrintCSS template.CSS
+	ThemeCSS template.CSS
+}
+
+// Export renders the slides into a single self-contained HTML page. Scripts,
+// style sheets and local images are inlined, so the page works from file://
+// without a server. Playground is not available without a server, so
+// runnable snippets are rendered as static code.
+func Export(w io.Writer, filename, format, themeName, encoding string) error {
+	parse, err := parserOf(format)
+	if err != nil {
+		return err
+	}
+
+	b, err := readSource(filename, encoding)
+	if err != nil {
+		return err
+	}
+
+	th, err := loadTheme(themeName, b, filename)
+	if err != nil {
+		return err
+	}
+
+	doc, _, err := parseSource(b, filename, parse)
+	if err != nil {
+		return err
+	}
+
+	notes := extractNotes(doc)
+	highlightSections(doc.Sections, highlightLang(b))
+
+	images, err := inlineImages(filepath.Dir(filename), doc.Sections)
+	if err != nil {
+		return err
+	}
+
+	themeCSS, err := inlineThemeCSS(th)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := newTemplate(th)
+	if err != nil {
+		return err
+	}
+
+	alts := ImageAlts(b)
+	tmpl = tmpl.Funcs(template.FuncMap{
+		"assetURL": func(u string) interface{} {
+			if data, ok := images[u]; ok {
+				return data
+			}
+			return u
+		},
+		"alt": func(u string) string { return alts[u] },
+	})
+
+	data := slidesData{
+		Doc:      doc,
+		Notes:    notes,
+		Template: tmpl,
+		Theme:    th,
+		Inline: &inlineAssets{
+			SlidesJS: template.JS(static.Slides_js),
+			StyleCSS: template.CSS(static.Styles_css),
+			PrintCSS: template.CSS(static.Print_css),
+			ThemeCSS: themeCSS,
+		},
+	}
+
+	return tmpl.ExecuteTemplate(w, "root", data)
+}
+
+// inlineImages reads local images used by the slides and returns them as
+// data URIs keyed by the URL written in the slides.
+func inlineImages(dir string, sections []present.Section) (map[string]template.URL, error) {
+	images := make(map[string]template.URL)
+
+	var walk func(sections []present.Section) error
+	walk = func(sections []present.Section) error {
+		for _, s := range sections {
+			for _, e := range s.Elem {
+				switch t := e.(type) {
+				case present.Section:
+					if err := walk([]present.Section{t}); err != nil {
+						return err
+					}
+				case present.Image:
+					if _, ok := images[t.URL]; ok || !IsLocalURL(t.URL) {
+						continue
+					}
+
+					b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(t.URL)))
+					if err != nil {
+						return fmt.Errorf("while inlining image: %v", err)
+					}
+
+					images[t.URL] = dataURI(t.URL, b)
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(sections); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+var cssURLRE = regexp.MustCompile(`url\(\s*['"]?([^'")]+?)['"]?\s*\)`)
+
+// inlineThemeCSS returns the style sheet of the theme with fonts and images
+// in the theme directory replaced by data URIs.
+func inlineThemeCSS(th *theme.Theme) (template.CSS, error) {
+	var err error
+
+	css := cssURLRE.ReplaceAllStringFunc(th.CSS, func(s string) string {
+		u := cssURLRE.FindStringSubmatch(s)[1]
+
+		if !IsLocalURL(u) || err != nil {
+			return s
+		}
+
+		path, ok := th.AssetFile(u)
+		if !ok {
+			return s
+		}
+
+		b, e := ioutil.ReadFile(path)
+		if e != nil {
+			err = fmt.Errorf("while inlining theme: %v", e)
+			return s
+		}
+
+		return fmt.Sprintf("url(%s)", dataURI(path, b))
+	})
+
+	return template.CSS(css), err
+}
+
+func dataURI(name string, b []byte) template.URL {
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(b)
+	}
+
+	return template.URL(fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(b)))
+}
@@ -3,135 +3,339 @@ package renderer
 import (
 	"bytes"
 	"carousel/templates"
+	"carousel/theme"
 	"code.google.com/p/go.tools/present"
 	"fmt"
 	"github.com/scryner/logg"
-	"github.com/suapapa/go_hangul/encoding/cp949"
 	"html/template"
 	"io"
 	"io/ioutil"
-	"os"
+	"net/url"
 	"path/filepath"
-	"unicode/utf8"
+	"strings"
+	"sync"
 )
 
-var _utf8_bom_header []byte = []byte{0xef, 0xbb, 0xbf}
+func init() {
+	// present decides by this global whether .play snippets carry the code
+	// hidden around them. Keep it always on, and let each renderer decide
+	// whether its snippets are runnable.
+	present.PlayEnabled = true
+}
 
 type FileRenderer struct {
 	filename    string
-	rendFun     renderFunc
+	themeName   string
+	encoding    string
+	parse       docParser
 	playEnabled bool
+	liveReload  bool
+
+	mu      sync.Mutex
+	state   *renderState // swapped as a whole by refreshes
+	pending *refreshCall // refresh which callers are waiting for to start
+	running sync.Mutex   // held while refreshing
 
 	logger *logg.Logger
 }
 
-func NewFileRenderer(filename string, playEnabled bool) *FileRenderer {
+// renderState is the outcome of a refresh. It is never modified once built,
+// except its output is cached at the first request, so requests keep
+// rendering the one they got while another is built.
+type renderState struct {
+	rendFun  renderFunc // of the last good version; nil if none yet
+	goodSum  []byte     // hash of the last good version
+	deps     []string
+	snippets []Snippet // of .play in the last good version
+	err      error     // of the last refresh
+	cache    *renderCache
+}
+
+// refreshCall is a refresh shared by the callers which asked for it before
+// it started.
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewFileRenderer returns a renderer of slides in the present format.
+// themeName overrides the theme given by the #theme directive of the slides,
+// and an empty one leaves it to the slides. On the contrary, the #encoding
+// directive overrides encoding.
+func NewFileRenderer(filename, themeName, encoding string, playEnabled, liveReload bool) *FileRenderer {
 	return &FileRenderer{
 		filename:    filename,
+		themeName:   themeName,
+		encoding:    encoding,
+		parse:       parseDocument,
 		playEnabled: playEnabled,
+		liveReload:  liveReload,
+		state:       &renderState{},
 		logger:      logg.GetDefaultLogger("renderer"),
 	}
 }
 
+// Render renders the last good version of the slides, with the error of the
+// last refresh on top of it if that failed. If the slides have never been
+// rendered successfully, it refreshes them and returns the error instead,
+// so that the author sees what to fix.
 func (rend *FileRenderer) Render(w io.Writer) error {
-	if rend.rendFun == nil {
-		if err := rend.Refresh(); err != nil {
-			return err
-		}
+	r, err := rend.Rendered()
+	if err != nil {
+		return err
 	}
 
-	return rend.rendFun(w)
+	_, err = w.Write(r.Body)
+	return err
 }
 
+// Rendered returns what Render writes, which is rendered once for each
+// content of the slides and their dependencies.
+func (rend *FileRenderer) Rendered() (*Rendered, error) {
+	st := rend.current()
+	if st.rendFun == nil {
+		rend.Refresh()
+		st = rend.current()
+	}
+
+	if st.rendFun == nil {
+		return nil, st.err
+	}
+
+	return st.cache.get(st.rendFun, st.err)
+}
+
+// Refresh reads the slides again. Callers arriving while a refresh is
+// running share the single one which starts after it, so a burst of
+// requests reads the files only once more.
 func (rend *FileRenderer) Refresh() error {
+	rend.mu.Lock()
+	c := rend.pending
+	if c == nil {
+		c = &refreshCall{done: make(chan struct{})}
+		rend.pending = c
+	}
+	rend.mu.Unlock()
+
+	rend.running.Lock()
+
+	// the first caller to get here runs the refresh for everyone waiting
+	rend.mu.Lock()
+	mine := rend.pending == c
+	if mine {
+		rend.pending = nil
+	}
+	rend.mu.Unlock()
+
+	if mine {
+		c.err = rend.refresh()
+		close(c.done)
+	}
+
+	rend.running.Unlock()
+
+	<-c.done
+	return c.err
+}
+
+func (rend *FileRenderer) refresh() error {
 	rend.logger.Debugf("renderer will be refreshed")
 
-	var err error
-	rend.rendFun, err = getRenderFunc(rend.filename, rend.playEnabled)
+	rendFun, deps, snippets, err := rend.getRenderFunc()
+
+	// keep files read so far even if parsing failed, so that a watcher
+	// notices when the broken dependency gets fixed
+	st := &renderState{
+		rendFun:  rendFun,
+		deps:     deps,
+		snippets: snippets,
+		err:      err,
+	}
+
+	last := rend.current()
+
+	// the output also depends on how the slides are rendered
+	h := hashOf(rend.themeName, rend.encoding, fmt.Sprint(rend.playEnabled, rend.liveReload))
+	if err != nil {
+		// keep serving the last good version, with the error over it
+		st.rendFun = last.rendFun
+		st.goodSum = last.goodSum
+		st.snippets = last.snippets
+		fmt.Fprintf(h, "%x\x00%v", last.goodSum, err)
+	}
+
+	st.cache = newRenderCache(last.cache, h, append([]string{rend.filename}, deps...))
+	if err == nil {
+		st.goodSum = st.cache.sum
+	}
+
+	rend.mu.Lock()
+	rend.state = st
+	rend.mu.Unlock()
 
 	return err
 }
 
-func getRenderFunc(filename string, playEnabled bool) (rendFunc renderFunc, err error) {
-	// read file
-	f, err := os.Open(filename)
+func (rend *FileRenderer) current() *renderState {
+	rend.mu.Lock()
+	defer rend.mu.Unlock()
+
+	return rend.state
+}
+
+// Info returns the header of the slides.
+func (rend *FileRenderer) Info() (*DeckInfo, error) {
+	return ReadDeckInfo(rend.filename, rend.encoding)
+}
+
+// Files returns the input file and every file it pulled in through
+// .code, .play, .html, .image, .iframe and its theme at the last refresh.
+func (rend *FileRenderer) Files() []string {
+	files := []string{rend.filename}
+	return append(files, rend.current().deps...)
+}
+
+// Snippets returns runnable snippets of .play of the last good version.
+func (rend *FileRenderer) Snippets() []Snippet {
+	return rend.current().snippets
+}
+
+func (rend *FileRenderer) getRenderFunc() (rendFunc renderFunc, deps []string, snippets []Snippet, err error) {
+	b, err := readSource(rend.filename, rend.encoding)
 	if err != nil {
 		return
 	}
-	defer f.Close()
 
-	b, err := ioutil.ReadAll(f)
+	th, err := loadTheme(rend.themeName, b, rend.filename)
 	if err != nil {
 		return
 	}
 
-	// validate document is utf-8
-	if !utf8.Valid(b) {
-		// it may be cp949, so convert it to utf-8
-		var b2 []byte
-		b2, err = cp949.From(b)
-		if err != nil {
-			return
-		}
+	// changes of the theme also reload the slides
+	deps = th.Files()
 
-		b = b2
+	doc, docDeps, err := parseSource(b, rend.filename, rend.parse)
+	deps = append(deps, docDeps...)
+	if err != nil {
+		return
 	}
 
-	// determine document has BOM
-	hasBom := false
-	for i, bom := range _utf8_bom_header {
-		if bom == b[i] {
-			hasBom = true
-		} else {
-			hasBom = false
-		}
-	}
+	notes := extractNotes(doc)
+	snippets = PlaySnippets(doc)
+	highlightSections(doc.Sections, highlightLang(b))
 
-	// eliminate BOM if it exist
-	if hasBom {
-		b = b[len(_utf8_bom_header):]
+	// templating
+	tmpl, err := newTemplate(th)
+	if err != nil {
+		return
 	}
 
-	// set playable
-	if playEnabled {
-		present.PlayEnabled = true
-	} else {
-		present.PlayEnabled = false
+	playEnabled, liveReload := rend.playEnabled, rend.liveReload
+
+	alts := ImageAlts(b)
+	tmpl = tmpl.Funcs(template.FuncMap{
+		"alt":      func(u string) string { return alts[u] },
+		"playable": func(c present.Code) bool { return playEnabled && c.Play },
+	})
+
+	rendFunc = renderFunc(func(w io.Writer, failure error) error {
+		data := slidesData{
+			Doc:         doc,
+			Notes:       notes,
+			Template:    tmpl,
+			Theme:       th,
+			PlayEnabled: playEnabled,
+			LiveReload:  liveReload,
+			Failure:     failure,
+		}
+		return tmpl.ExecuteTemplate(w, "root", data)
+	})
+
+	return
+}
+
+// slidesData is passed to the root template.
+type slidesData struct {
+	*present.Doc
+	Notes       [][]string // speaker notes for each slide
+	Template    *template.Template
+	Theme       *theme.Theme
+	PlayEnabled bool
+	LiveReload  bool
+
+	// Failure is the error of the last refresh when an older version of
+	// the slides is shown.
+	Failure error
+
+	// Inline holds contents of static files when they should be embedded
+	// into the page instead of being linked.
+	Inline *inlineAssets
+}
+
+// loadTheme loads the theme given by the user, or the one given by the
+// #theme directive of the slides if the user gave none. A theme directory
+// in the directive is relative to the slides.
+func loadTheme(themeName string, b []byte, filename string) (*theme.Theme, error) {
+	if themeName != "" {
+		return theme.Load(themeName, ".")
 	}
 
-	// parse
+	return theme.Load(readDirectives(b)["theme"], filepath.Dir(filename))
+}
+
+// parseSource parses the slides read from filename. deps has the files the
+// slides depend on, which is filled as far as possible even if parsing
+// failed.
+func parseSource(b []byte, filename string, parse docParser) (doc *present.Doc, deps []string, err error) {
 	nr := bytes.NewBuffer(b)
-	doc, err := parseDocument(nr, filepath.Dir(filename), "slides", 0)
+	dir := filepath.Dir(filename)
+	doc, deps, err = parse(nr, dir, "slides", 0)
 	if err != nil {
-		err = fmt.Errorf("while parsing: %v", err.Error())
+		err = newParseError(filename, b, dir, err)
 		return
 	}
 
-	// templating
+	deps = append(deps, assetFiles(dir, doc.Sections)...)
+
+	return
+}
+
+func newTemplate(th *theme.Theme) (*template.Template, error) {
 	tmpl := present.Template()
-	tmpl = tmpl.Funcs(template.FuncMap{"playable": playable})
+	tmpl = tmpl.Funcs(template.FuncMap{
+		"playable": playable,
+		"assetURL": assetURL,
+		"alt":      alt,
+	})
+
+	// templates of the theme override the default ones
+	ss := []string{templates.Action_tmpl}
+	if th.ActionTmpl != templates.Action_tmpl {
+		ss = append(ss, th.ActionTmpl)
+	}
+	ss = append(ss, th.SlidesTmpl)
 
-	tmpl, err = parseTemplates(tmpl, templates.Action_tmpl, templates.Slides_tmpl)
+	tmpl, err := parseTemplates(tmpl, ss...)
 	if err != nil {
-		err = fmt.Errorf("while templating: %v", err.Error())
-		return
+		return nil, fmt.Errorf("while templating: %v", err.Error())
 	}
 
-	rendFunc = renderFunc(func(w io.Writer) error {
-		data := struct {
-			*present.Doc
-			Template    *template.Template
-			PlayEnabled bool
-		}{doc, tmpl, playEnabled}
-		return tmpl.ExecuteTemplate(w, "root", data)
-	})
+	return tmpl, nil
+}
 
-	return
+// assetURL is replaced while exporting to point to inlined contents.
+func assetURL(u string) interface{} {
+	return u
 }
 
+// alt is replaced by alternative texts of images in the slides.
+func alt(u string) string {
+	return ""
+}
+
+// playable is replaced by whether the renderer runs the snippet.
 func playable(c present.Code) bool {
-	// return present.PlayEnabled && c.Play
-	return present.PlayEnabled
+	return false
 }
 
 func parseTemplates(t *template.Template, ss ...string) (*template.Template, error) {
@@ -151,11 +355,62 @@ func parseTemplates(t *template.Template, ss ...string) (*template.Template, err
 	return t, nil
 }
 
-func parseDocument(r io.Reader, dir, name string, mode present.ParseMode) (*present.Doc, error) {
+// parseDocument parses the document and also returns the files read by
+// directives such as .code, .play and .html.
+func parseDocument(r io.Reader, dir, name string, mode present.ParseMode) (doc *present.Doc, deps []string, err error) {
+	// present panics on some malformed directives such as ".image" without
+	// arguments
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("malformed directive: %v", e)
+		}
+	}()
+
 	readFile := func(filename string) ([]byte, error) {
-		return ioutil.ReadFile(filepath.Join(dir, filename))
+		path := filepath.Join(dir, filename)
+		deps = append(deps, path)
+		return ioutil.ReadFile(path)
 	}
 
 	ctx := present.Context{ReadFile: readFile}
-	return ctx.Parse(r, name, mode)
+	doc, err = ctx.Parse(r, name, mode)
+	return doc, deps, err
+}
+
+// assetFiles returns local files referred by .image and .iframe elements.
+func assetFiles(dir string, sections []present.Section) (files []string) {
+	for _, s := range sections {
+		for _, e := range s.Elem {
+			var u string
+
+			switch t := e.(type) {
+			case present.Section:
+				files = append(files, assetFiles(dir, []present.Section{t})...)
+			case present.Image:
+				u = t.URL
+			case present.Iframe:
+				u = t.URL
+			}
+
+			if u != "" && IsLocalURL(u) {
+				files = append(files, filepath.Join(dir, filepath.FromSlash(u)))
+			}
+		}
+	}
+
+	return
+}
+
+// IsLocalURL tells whether the URL in slides refers a file next to them.
+func IsLocalURL(u string) bool {
+	if strings.HasPrefix(u, "//") {
+		return false
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Scheme == "" && parsed.Host == ""
 }
@@ -0,0 +1,133 @@
+package renderer
+
+import (
+	"bytes"
+	"code.google.com/p/go.tools/present"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSlides = `Title of slides
+
+* First slide
+
+Hello
+`
+
+func writeSlides(t *testing.T, s string) string {
+	dir, err := ioutil.TempDir("", "carousel-renderer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(dir, "talk.slide")
+	if err := ioutil.WriteFile(filename, []byte(s), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
+func TestRefreshCoalesces(t *testing.T) {
+	filename := writeSlides(t, testSlides)
+	defer os.RemoveAll(filepath.Dir(filename))
+
+	rend := NewFileRenderer(filename, "", EncodingAuto, false, false)
+
+	var parses int32
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	rend.parse = func(r io.Reader, dir, name string, mode present.ParseMode) (*present.Doc, []string, error) {
+		if atomic.AddInt32(&parses, 1) == 1 {
+			entered <- struct{}{}
+			<-release
+		}
+		return parseDocument(r, dir, name, mode)
+	}
+
+	var wg sync.WaitGroup
+	refresh := func() {
+		defer wg.Done()
+		if err := rend.Refresh(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	wg.Add(1)
+	go refresh()
+	<-entered
+
+	// a burst of refreshes while the first one is running
+	const burst = 10
+	wg.Add(burst)
+	for i := 0; i < burst; i++ {
+		go refresh()
+	}
+
+	// let every one of them wait for the running refresh
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&parses); n != 2 {
+		t.Errorf("slides are parsed %d times; want 2, the first refresh and one for the burst", n)
+	}
+}
+
+func TestFailedRefreshKeepsLastGood(t *testing.T) {
+	filename := writeSlides(t, testSlides)
+	defer os.RemoveAll(filepath.Dir(filename))
+
+	rend := NewFileRenderer(filename, "", EncodingAuto, false, false)
+
+	good, err := rend.Rendered()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(good.Body, []byte("Hello")) {
+		t.Fatalf("rendered slides lack their text:\n%s", good.Body)
+	}
+
+	broken := strings.Replace(testSlides, "Hello", ".code missing.go", 1)
+	if err := ioutil.WriteFile(filename, []byte(broken), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rend.Refresh(); err == nil {
+		t.Fatal("refresh of broken slides succeeds")
+	}
+
+	r, err := rend.Rendered()
+	if err != nil {
+		t.Fatalf("rendering after a failed refresh: %v", err)
+	}
+	if !bytes.Contains(r.Body, []byte("Hello")) {
+		t.Errorf("the last good version is not rendered after a failed refresh:\n%s", r.Body)
+	}
+	if !bytes.Contains(r.Body, []byte("missing.go")) {
+		t.Errorf("the error of the failed refresh is not shown:\n%s", r.Body)
+	}
+
+	// fixing the slides renders them anew
+	if err := ioutil.WriteFile(filename, []byte(strings.Replace(testSlides, "Hello", "Fixed", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rend.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = rend.Rendered()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(r.Body, []byte("Fixed")) || bytes.Contains(r.Body, []byte("missing.go")) {
+		t.Errorf("fixed slides are not rendered:\n%s", r.Body)
+	}
+}
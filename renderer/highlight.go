@@ -0,0 +1,149 @@
+package renderer
+
+import (
+	"bytes"
+	"carousel/highlight"
+	"code.google.com/p/go.tools/present"
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// language of indented blocks unless the #highlight directive tells
+const _DEFAULT_HIGHLIGHT = "go"
+
+// highlightedText is an indented block with its syntax highlighted.
+type highlightedText struct {
+	HTML template.HTML
+}
+
+func (highlightedText) TemplateName() string { return "highlighted" }
+
+// a line of code rendered by present, which may be the first one of <pre>
+var codeLineRE = regexp.MustCompile(`^(<pre[^>]*>)?<span num="(\d+)">(.*)</span>$`)
+
+// highlightLang returns the language of indented blocks of the slides.
+func highlightLang(b []byte) string {
+	if lang, ok := readDirectives(b)["highlight"]; ok {
+		return lang
+	}
+
+	return _DEFAULT_HIGHLIGHT
+}
+
+// highlightSections highlights code of .code and .play by its file
+// extension, and indented blocks in the language lang; "none" leaves
+// indented blocks as they are.
+func highlightSections(sections []present.Section, lang string) {
+	for i := range sections {
+		highlightElems(sections[i].Elem, lang)
+	}
+}
+
+func highlightElems(elems []present.Elem, lang string) {
+	for i, e := range elems {
+		switch t := e.(type) {
+		case present.Section:
+			highlightElems(t.Elem, lang)
+
+		case present.Code:
+			if lex, ok := highlight.Lookup(t.Ext); ok {
+				t.Text = highlightCode(lex, t.Text)
+				elems[i] = t
+			}
+
+		case present.Text:
+			if !t.Pre {
+				break
+			}
+
+			if lex, ok := highlight.Lookup(lang); ok {
+				var b bytes.Buffer
+				for i, tokens := range highlight.Lines(lex, strings.Join(t.Lines, "\n")) {
+					if i > 0 {
+						b.WriteByte('\n')
+					}
+					b.WriteString(string(highlight.HTML(tokens)))
+				}
+				elems[i] = highlightedText{template.HTML(b.String())}
+			}
+		}
+	}
+}
+
+// highlightCode highlights lines of code rendered by present, keeping the
+// lines highlighted by HL markers in bold. Code hidden around snippets of
+// the playground is left as it is.
+func highlightCode(lex highlight.Lexer, text template.HTML) template.HTML {
+	rendered := strings.Split(string(text), "\n")
+
+	type codeLine struct {
+		index  int // in rendered
+		open   string
+		num    string
+		marked bool
+	}
+
+	var (
+		lines []codeLine
+		src   []string
+	)
+
+	for i, l := range rendered {
+		m := codeLineRE.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+
+		code := m[3]
+		marked := strings.Contains(code, "<b>")
+		if marked {
+			code = strings.Replace(strings.Replace(code, "<b>", "", 1), "</b>", "", 1)
+		}
+
+		lines = append(lines, codeLine{i, m[1], m[2], marked})
+		src = append(src, html.UnescapeString(code))
+	}
+
+	if len(lines) == 0 {
+		return text
+	}
+
+	tokens := highlight.Lines(lex, strings.Join(src, "\n"))
+
+	for i, l := range lines {
+		var code string
+		if l.marked {
+			indent, rest := splitIndent(tokens[i])
+			code = html.EscapeString(indent) + "<b>" + string(highlight.HTML(rest)) + "</b>"
+		} else {
+			code = string(highlight.HTML(tokens[i]))
+		}
+
+		rendered[l.index] = fmt.Sprintf(`%s<span num="%s">%s</span>`, l.open, l.num, code)
+	}
+
+	return template.HTML(strings.Join(rendered, "\n"))
+}
+
+// splitIndent splits leading white spaces off the tokens of a line.
+func splitIndent(tokens []highlight.Token) (string, []highlight.Token) {
+	var indent string
+
+	for len(tokens) > 0 {
+		t := tokens[0]
+		trimmed := strings.TrimLeftFunc(t.Text, unicode.IsSpace)
+		indent += t.Text[:len(t.Text)-len(trimmed)]
+
+		if trimmed != "" {
+			tokens = append([]highlight.Token{{Class: t.Class, Text: trimmed}}, tokens[1:]...)
+			break
+		}
+		tokens = tokens[1:]
+	}
+
+	return indent, tokens
+}
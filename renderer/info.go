@@ -0,0 +1,117 @@
+package renderer
+
+import (
+	"bytes"
+	"code.google.com/p/go.tools/present"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DeckInfo summarizes the header of slides for listing.
+type DeckInfo struct {
+	Title    string
+	Subtitle string
+	Time     time.Time
+	Authors  []string
+	Tags     []string
+}
+
+// ReadDeckInfo parses only the header of the slides, so that referred
+// files are never read.
+func ReadDeckInfo(filename, encoding string) (*DeckInfo, error) {
+	b, err := readSource(filename, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := present.Parse(bytes.NewReader(b), filename, present.TitlesOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDeckInfo(doc, readAuthors(b)), nil
+}
+
+// readMarkdownDeckInfo is ReadDeckInfo of slides written in Markdown.
+func readMarkdownDeckInfo(filename, encoding string) (*DeckInfo, error) {
+	b, err := readSource(filename, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, _, err := parseMarkdown(bytes.NewReader(b), filepath.Dir(filename), filename, present.TitlesOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDeckInfo(doc, readMarkdownAuthors(b)), nil
+}
+
+func newDeckInfo(doc *present.Doc, authors []string) *DeckInfo {
+	return &DeckInfo{
+		Title:    doc.Title,
+		Subtitle: doc.Subtitle,
+		Time:     doc.Time,
+		Tags:     doc.Tags,
+		Authors:  authors,
+	}
+}
+
+// readAuthors returns names of authors. TitlesOnly parsing stops before the
+// author block, so the block is parsed by itself with an empty section
+// appended to end it.
+func readAuthors(b []byte) (authors []string) {
+	var head []string
+
+	for _, l := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(l, "* ") {
+			break
+		}
+		head = append(head, strings.TrimRight(l, "\r"))
+	}
+	for len(head) > 0 && strings.TrimSpace(head[len(head)-1]) == "" {
+		head = head[:len(head)-1]
+	}
+	head = append(head, "", "* -")
+
+	doc, err := present.Parse(strings.NewReader(strings.Join(head, "\n")), "header", 0)
+	if err != nil {
+		return nil
+	}
+
+	return authorNames(doc.Authors)
+}
+
+// readMarkdownAuthors returns names of authors of slides written in
+// Markdown, which come after the header up to the first heading.
+func readMarkdownAuthors(b []byte) []string {
+	text := strings.Split(string(b), "\n")
+	for i := range text {
+		text[i] = strings.TrimRight(text[i], "\r")
+	}
+
+	lines := &mdLines{text: text}
+	if err := parseMarkdownHeader(new(present.Doc), lines, "header"); err != nil {
+		return nil
+	}
+
+	return authorNames(parseMarkdownAuthors(lines))
+}
+
+func authorNames(all []present.Author) (authors []string) {
+	for _, a := range all {
+		var name []string
+		for _, e := range a.TextElem() {
+			if t, ok := e.(present.Text); ok {
+				name = append(name, t.Lines...)
+			}
+		}
+
+		if len(name) > 0 {
+			authors = append(authors, strings.Join(name, ", "))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,45 @@
+package renderer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	tests := []struct {
+		rend interface {
+			Info() (*DeckInfo, error)
+		}
+		want DeckInfo
+	}{
+		{
+			rend: NewFileRenderer("../samples/helloworld.slide", "", EncodingAuto, false, false),
+			want: DeckInfo{
+				Title:    "Hello, world!",
+				Subtitle: "(A sample for playground)",
+				Authors:  []string{"scryner"},
+			},
+		},
+		{
+			rend: NewMarkdownRenderer("../samples/markdown.md", "", EncodingAuto, false, false),
+			want: DeckInfo{
+				Title:    "Hello, Markdown!",
+				Subtitle: "(A sample written in Markdown)",
+				Authors:  []string{"scryner"},
+				Tags:     []string{"markdown", "sample"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		info, err := test.rend.Info()
+		if err != nil {
+			t.Errorf("%s: %v", test.want.Title, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(*info, test.want) {
+			t.Errorf("got %+v; want %+v", *info, test.want)
+		}
+	}
+}
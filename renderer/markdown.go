@@ -0,0 +1,572 @@
+package renderer
+
+import (
+	"bufio"
+	"bytes"
+	"code.google.com/p/go.tools/present"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Formats of slides.
+const (
+	FormatPresent  = "present"
+	FormatMarkdown = "markdown"
+)
+
+// FormatOf returns the format of the slide file guessed by its extension.
+func FormatOf(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return FormatMarkdown
+	}
+
+	return FormatPresent
+}
+
+// IsDeckFile tells whether the file is of slides in either format by its
+// extension, as FormatOf guesses.
+func IsDeckFile(filename string) bool {
+	return filepath.Ext(filename) == ".slide" || FormatOf(filename) == FormatMarkdown
+}
+
+// parserOf returns the document parser of the format.
+func parserOf(format string) (docParser, error) {
+	switch format {
+	case FormatPresent:
+		return parseDocument, nil
+	case FormatMarkdown:
+		return parseMarkdown, nil
+	}
+
+	return nil, fmt.Errorf("unknown format '%s'", format)
+}
+
+// MarkdownRenderer renders slides written in Markdown. Headings of level 1
+// and 2 start slides, and the result is the same document as the present
+// format, so it is rendered by the same templates.
+//
+// The first heading is the title of the slides. Lines right after it are
+// the subtitle, the date and tags as in the present format, and following
+// paragraphs up to the first slide are the authors. Directives such as
+// "#theme: dark" may be put anywhere in the header.
+type MarkdownRenderer struct {
+	*FileRenderer
+}
+
+func NewMarkdownRenderer(filename, themeName, encoding string, playEnabled, liveReload bool) *MarkdownRenderer {
+	rend := &MarkdownRenderer{NewFileRenderer(filename, themeName, encoding, playEnabled, liveReload)}
+	rend.parse = parseMarkdown
+
+	return rend
+}
+
+// Info returns the header of the slides.
+func (rend *MarkdownRenderer) Info() (*DeckInfo, error) {
+	return readMarkdownDeckInfo(rend.filename, rend.encoding)
+}
+
+// MarkdownHeadingRE matches headings of Markdown with their levels and
+// text, and MarkdownFenceRE fences of code with the info string after.
+var (
+	MarkdownHeadingRE = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	MarkdownFenceRE   = regexp.MustCompile("^(```+|~~~+)\\s*(.*)$")
+)
+
+var (
+	mdBulletRE = regexp.MustCompile(`^\s{0,3}(?:[-*+]|[0-9]+[.)])\s+(.*)$`)
+	mdImageRE  = regexp.MustCompile(`^!\[([^\]]*)\]\(\s*([^\s)]+)(?:\s+"[^"]*")?\s*\)$`)
+	mdRuleRE   = regexp.MustCompile(`^\s{0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+)
+
+// mdLines walks lines of a Markdown document.
+type mdLines struct {
+	text []string
+	line int // index of the next line
+}
+
+func (l *mdLines) next() (string, bool) {
+	current := l.line
+	l.line++
+
+	if current >= len(l.text) {
+		return "", false
+	}
+
+	return l.text[current], true
+}
+
+func (l *mdLines) back() {
+	l.line--
+}
+
+func (l *mdLines) nextNonEmpty() (string, bool) {
+	for {
+		text, ok := l.next()
+		if !ok || strings.TrimSpace(text) != "" {
+			return text, ok
+		}
+	}
+}
+
+// parseMarkdown parses a Markdown document into a present document. It
+// refers no file, so no dependency is returned.
+func parseMarkdown(r io.Reader, dir, name string, mode present.ParseMode) (*present.Doc, []string, error) {
+	var text []string
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		text = append(text, strings.TrimRight(s.Text(), "\r"))
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	lines := &mdLines{text: text}
+	doc := new(present.Doc)
+
+	if err := parseMarkdownHeader(doc, lines, name); err != nil {
+		return nil, nil, err
+	}
+
+	if mode&present.TitlesOnly != 0 {
+		return doc, nil, nil
+	}
+
+	doc.Authors = parseMarkdownAuthors(lines)
+
+	sections, err := parseMarkdownSections(lines, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc.Sections = sections
+
+	return doc, nil, nil
+}
+
+func parseMarkdownHeader(doc *present.Doc, lines *mdLines, name string) error {
+	text, ok := lines.nextNonEmpty()
+	for ok && isDirective(text) {
+		text, ok = lines.nextNonEmpty()
+	}
+	if !ok {
+		return fmt.Errorf("%s: unexpected EOF; expected title", name)
+	}
+
+	m := MarkdownHeadingRE.FindStringSubmatch(text)
+	if m == nil || len(m[1]) != 1 {
+		return fmt.Errorf("%s:%d: expected title as '# heading'", name, lines.line)
+	}
+	doc.Title = m[2]
+
+	for {
+		text, ok := lines.next()
+		if !ok || strings.TrimSpace(text) == "" {
+			break
+		}
+
+		if isMarkdownHeading(text) {
+			lines.back()
+			break
+		}
+
+		if isDirective(text) {
+			continue
+		}
+
+		const tagPrefix = "Tags:"
+		if strings.HasPrefix(text, tagPrefix) {
+			tags := strings.Split(text[len(tagPrefix):], ",")
+			for i := range tags {
+				tags[i] = strings.TrimSpace(tags[i])
+			}
+			doc.Tags = append(doc.Tags, tags...)
+		} else if t, ok := parseHeaderTime(text); ok {
+			doc.Time = t
+		} else if doc.Subtitle == "" {
+			doc.Subtitle = text
+		} else {
+			return fmt.Errorf("%s:%d: unexpected header line: %q", name, lines.line, text)
+		}
+	}
+
+	return nil
+}
+
+// parseHeaderTime parses dates in the same layouts as the present format.
+func parseHeaderTime(text string) (time.Time, bool) {
+	if t, err := time.Parse("15:04 2 Jan 2006", text); err == nil {
+		return t, true
+	}
+
+	if t, err := time.Parse("2 Jan 2006", text); err == nil {
+		// at 11am UTC it is the same date everywhere
+		return t.Add(time.Hour * 11), true
+	}
+
+	return time.Time{}, false
+}
+
+func parseMarkdownAuthors(lines *mdLines) (authors []present.Author) {
+	var a *present.Author
+
+	for {
+		text, ok := lines.next()
+		if !ok {
+			break
+		}
+
+		if isMarkdownHeading(text) {
+			lines.back()
+			break
+		}
+
+		if isDirective(text) {
+			continue
+		}
+
+		text = strings.TrimSpace(text)
+		if text == "" {
+			if a != nil {
+				authors = append(authors, *a)
+				a = nil
+			}
+			continue
+		}
+
+		if a == nil {
+			a = new(present.Author)
+		}
+
+		// same as the present format: twitter names, links and emails
+		var el present.Elem
+		switch {
+		case strings.HasPrefix(text, "@"):
+			el = authorLink("http://twitter.com/"+text[1:], text)
+		case strings.Contains(text, ":"):
+			el = authorLink(text, text)
+		case strings.Contains(text, "@"):
+			el = authorLink("mailto:"+text, text)
+		}
+		if el == nil {
+			el = present.Text{Lines: []string{text}}
+		}
+
+		a.Elem = append(a.Elem, el)
+	}
+
+	if a != nil {
+		authors = append(authors, *a)
+	}
+
+	return
+}
+
+func authorLink(rawURL, label string) present.Elem {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	return present.Link{URL: u, Label: label}
+}
+
+func isMarkdownHeading(text string) bool {
+	return MarkdownHeadingRE.MatchString(text)
+}
+
+// parseMarkdownSections parses slides. Headings of level 1 and 2 start
+// slides, and deeper ones start sub-sections of the slide.
+func parseMarkdownSections(lines *mdLines, name string) ([]present.Section, error) {
+	var sections []present.Section
+
+	// section being filled; sub-sections are kept until the slide ends
+	var cur *present.Section
+	var sub *present.Section
+
+	flushSub := func() {
+		if sub != nil {
+			cur.Elem = append(cur.Elem, *sub)
+			sub = nil
+		}
+	}
+
+	flush := func() {
+		if cur != nil {
+			flushSub()
+			sections = append(sections, *cur)
+			cur = nil
+		}
+	}
+
+	for {
+		text, ok := lines.nextNonEmpty()
+		if !ok {
+			break
+		}
+
+		if m := MarkdownHeadingRE.FindStringSubmatch(text); m != nil {
+			if len(m[1]) <= 2 || cur == nil {
+				flush()
+				cur = &present.Section{
+					Number: []int{len(sections) + 1},
+					Title:  m[2],
+				}
+			} else {
+				flushSub()
+				sub = &present.Section{
+					Number: []int{cur.Number[0], len(cur.Sections()) + 1},
+					Title:  m[2],
+				}
+			}
+			continue
+		}
+
+		if cur == nil {
+			return nil, fmt.Errorf("%s:%d: expected a heading starting a slide", name, lines.line)
+		}
+
+		lines.back()
+		e, err := parseMarkdownBlock(lines, name)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			continue
+		}
+
+		if sub != nil {
+			sub.Elem = append(sub.Elem, e)
+		} else {
+			cur.Elem = append(cur.Elem, e)
+		}
+	}
+
+	flush()
+
+	return sections, nil
+}
+
+// parseMarkdownBlock parses an element starting at the next line, which is
+// not empty.
+func parseMarkdownBlock(lines *mdLines, name string) (present.Elem, error) {
+	text, _ := lines.next()
+
+	switch {
+	case MarkdownFenceRE.MatchString(text):
+		m := MarkdownFenceRE.FindStringSubmatch(text)
+		return parseMarkdownFence(lines, name, m[1], m[2])
+
+	case strings.HasPrefix(text, "    ") || strings.HasPrefix(text, "\t"):
+		var pre []string
+		for ok := true; ok && (strings.TrimSpace(text) == "" || strings.HasPrefix(text, "    ") || strings.HasPrefix(text, "\t")); text, ok = lines.next() {
+			if strings.HasPrefix(text, "\t") {
+				text = text[1:]
+			} else if len(text) >= 4 {
+				text = text[4:]
+			}
+			pre = append(pre, text)
+		}
+		lines.back()
+
+		s := strings.Join(pre, "\n")
+		s = strings.Replace(s, "\t", "    ", -1) // browsers treat tabs badly
+		s = strings.TrimRight(s, " \n")
+		return present.Text{Lines: []string{s}, Pre: true}, nil
+
+	case mdRuleRE.MatchString(text):
+		// slides are separated by headings already
+		return nil, nil
+
+	case mdBulletRE.MatchString(text):
+		var bullets []string
+		for ok := true; ok && strings.TrimSpace(text) != ""; text, ok = lines.next() {
+			if m := mdBulletRE.FindStringSubmatch(text); m != nil {
+				bullets = append(bullets, markdownInline(m[1]))
+			} else if len(bullets) > 0 && (text[0] == ' ' || text[0] == '\t') {
+				// continuation of the item
+				bullets[len(bullets)-1] += " " + markdownInline(strings.TrimSpace(text))
+			} else {
+				break
+			}
+		}
+		lines.back()
+		return present.List{Bullet: bullets}, nil
+
+	case mdImageRE.MatchString(strings.TrimSpace(text)):
+		m := mdImageRE.FindStringSubmatch(strings.TrimSpace(text))
+		return present.Image{URL: m[2]}, nil
+	}
+
+	// paragraph
+	var l []string
+	for ok := true; ok && strings.TrimSpace(text) != ""; text, ok = lines.next() {
+		if len(l) > 0 && startsMarkdownBlock(text) {
+			break
+		}
+
+		text = strings.TrimPrefix(strings.TrimPrefix(text, ">"), " ")
+		l = append(l, markdownInline(strings.TrimSpace(text)))
+	}
+	lines.back()
+
+	return present.Text{Lines: l}, nil
+}
+
+func startsMarkdownBlock(text string) bool {
+	return isMarkdownHeading(text) || MarkdownFenceRE.MatchString(text) || mdBulletRE.MatchString(text) ||
+		mdImageRE.MatchString(strings.TrimSpace(text))
+}
+
+// parseMarkdownFence parses a fenced code block into a code element. The
+// info string gives the language; a "play" word in it makes the snippet
+// runnable.
+func parseMarkdownFence(lines *mdLines, name, fence, info string) (present.Elem, error) {
+	start := lines.line
+
+	var code []string
+	for {
+		text, ok := lines.next()
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unclosed code block", name, start)
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(text), fence[:3]) && strings.TrimSpace(strings.Trim(strings.TrimSpace(text), fence[:1])) == "" {
+			break
+		}
+
+		code = append(code, text)
+	}
+
+	words := strings.Fields(info)
+	ext := ""
+	play := false
+	for i, w := range words {
+		if i == 0 && w != "play" {
+			ext = "." + w
+		}
+		if w == "play" {
+			play = true
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<pre>")
+	for i, l := range code {
+		fmt.Fprintf(&buf, "<span num=\"%d\">%s</span>\n", i+1, html.EscapeString(strings.Replace(l, "\t", "    ", -1)))
+	}
+	buf.WriteString("</pre>")
+
+	raw := strings.Join(code, "\n") + "\n"
+
+	return present.Code{
+		Text: template.HTML(buf.String()),
+		Play: play,
+		Ext:  ext,
+		Raw:  []byte(raw),
+	}, nil
+}
+
+// markdownInline converts inline Markdown markups into the present format:
+// **bold**, *italic*, `code` and [label](url). A backslash escapes
+// punctuation, which is taken as it is.
+func markdownInline(s string) string {
+	var b bytes.Buffer
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && isASCIIPunct(rest[1]):
+			b.WriteByte(rest[1])
+			i += 2
+			continue
+
+		case rest[0] == '`':
+			if end := strings.Index(rest[1:], "`"); end > 0 {
+				b.WriteString(fontWord('`', rest[1:1+end]))
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				b.WriteString(fontWord('*', rest[2:2+end]))
+				i += end + 4
+				continue
+			}
+
+		case (rest[0] == '*' || rest[0] == '_') && len(rest) > 1 && rest[1] != ' ':
+			if end := strings.IndexByte(rest[1:], rest[0]); end > 0 {
+				b.WriteString(fontWord('_', rest[1:1+end]))
+				i += end + 2
+				continue
+			}
+
+		case rest[0] == '[' || strings.HasPrefix(rest, "!["):
+			start := 1
+			if rest[0] == '!' {
+				start = 2
+			}
+
+			if m := mdLinkRE.FindStringSubmatch(rest[start-1:]); m != nil {
+				b.WriteString("[[" + m[2] + "][" + unescapeMarkdown(m[1]) + "]]")
+				i += start - 1 + len(m[0])
+				continue
+			}
+
+		case rest[0] == '<':
+			if end := strings.IndexByte(rest, '>'); end > 0 {
+				if u, err := url.Parse(rest[1:end]); err == nil && u.Scheme != "" {
+					b.WriteString("[[" + rest[1:end] + "]]")
+					i += end + 1
+					continue
+				}
+			}
+		}
+
+		b.WriteByte(s[i])
+		i++
+	}
+
+	return b.String()
+}
+
+// unescapeMarkdown takes punctuation escaped by a backslash as it is.
+func unescapeMarkdown(s string) string {
+	var b bytes.Buffer
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+var mdLinkRE = regexp.MustCompile(`^\[([^\]]+)\]\(\s*([^\s)]+)(?:\s+"[^"]*")?\s*\)`)
+
+// fontWord makes a single word of the present format out of text, as the
+// present format marks up fonts only for a word: inner spaces become
+// markers and markers become doubled.
+func fontWord(marker byte, text string) string {
+	m := string(marker)
+
+	text = strings.Replace(text, m, m+m, -1)
+	text = strings.Replace(text, " ", m, -1)
+
+	return m + text + m
+}
@@ -0,0 +1,59 @@
+package renderer
+
+import (
+	"code.google.com/p/go.tools/present"
+	"strings"
+)
+
+const notePrefix = ": "
+
+// extractNotes takes speaker notes, lines starting with ": ", out of the
+// slides and returns them for each slide. Notes in sub-sections belong to
+// the slide containing them.
+func extractNotes(doc *present.Doc) [][]string {
+	notes := make([][]string, len(doc.Sections))
+
+	for i := range doc.Sections {
+		notes[i] = extractSectionNotes(&doc.Sections[i])
+	}
+
+	return notes
+}
+
+func extractSectionNotes(s *present.Section) (notes []string) {
+	var elems []present.Elem
+
+	for _, e := range s.Elem {
+		switch t := e.(type) {
+		case present.Section:
+			notes = append(notes, extractSectionNotes(&t)...)
+			e = t
+		case present.Text:
+			if t.Pre {
+				break
+			}
+
+			var lines []string
+			for _, l := range t.Lines {
+				if strings.HasPrefix(l, notePrefix) {
+					notes = append(notes, strings.TrimPrefix(l, notePrefix))
+				} else {
+					lines = append(lines, l)
+				}
+			}
+
+			// paragraph of only notes
+			if len(lines) == 0 {
+				continue
+			}
+
+			t.Lines = lines
+			e = t
+		}
+
+		elems = append(elems, e)
+	}
+
+	s.Elem = elems
+	return
+}
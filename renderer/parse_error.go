@@ -0,0 +1,118 @@
+package renderer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lines shown before and after the failed line
+const _ERROR_CONTEXT_LINES = 3
+
+// ParseError tells where parsing slides failed.
+type ParseError struct {
+	File      string
+	Line      int    // 1-based line number, zero if unknown
+	Directive string // such as ".code" if the line is a directive
+	Message   string
+	Context   []SourceLine // lines around Line
+}
+
+// SourceLine is a line of slides shown with an error.
+type SourceLine struct {
+	Number int
+	Text   string
+	Failed bool
+}
+
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("while parsing %s: %s", e.File, e.Message)
+	}
+
+	return fmt.Sprintf("while parsing %s:%d: %s", e.File, e.Line, e.Message)
+}
+
+var (
+	// errors of present and Markdown parsers start with "slides:<line>"
+	errorLineRE = regexp.MustCompile(`^slides:(\d+):?\s*`)
+	quotedRE    = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+)
+
+// newParseError locates the error in source, the decoded slides. dir is
+// the directory where files of directives are read.
+func newParseError(filename string, source []byte, dir string, err error) *ParseError {
+	msg := strings.TrimSpace(err.Error())
+	lines := strings.Split(strings.TrimSuffix(string(source), "\n"), "\n")
+
+	e := &ParseError{
+		File:    filename,
+		Message: msg,
+	}
+
+	switch {
+	case errorLineRE.MatchString(msg):
+		m := errorLineRE.FindStringSubmatch(msg)
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Message = msg[len(m[0]):]
+
+	case quotedRE.MatchString(msg):
+		// some directives such as .image tell only the line failed
+		if s, err := strconv.Unquote(quotedRE.FindString(msg)); err == nil {
+			s = strings.TrimSpace(s)
+			e.Line = findLine(lines, func(l string) bool { return l == s })
+		}
+	}
+
+	// a file read by a directive is missing
+	if pe, ok := err.(*os.PathError); ok && e.Line == 0 {
+		if rel, err := filepath.Rel(dir, pe.Path); err == nil {
+			rel = filepath.ToSlash(rel)
+			e.Line = findLine(lines, func(l string) bool {
+				return strings.HasPrefix(l, ".") && strings.Contains(l, rel)
+			})
+		}
+	}
+
+	if e.Line <= 0 || e.Line > len(lines) {
+		e.Line = 0
+		return e
+	}
+
+	if text := lines[e.Line-1]; strings.HasPrefix(text, ".") {
+		e.Directive = strings.Fields(text)[0]
+	}
+
+	from := e.Line - _ERROR_CONTEXT_LINES
+	if from < 1 {
+		from = 1
+	}
+	to := e.Line + _ERROR_CONTEXT_LINES
+	if to > len(lines) {
+		to = len(lines)
+	}
+
+	for n := from; n <= to; n++ {
+		e.Context = append(e.Context, SourceLine{
+			Number: n,
+			Text:   strings.TrimRight(lines[n-1], "\r"),
+			Failed: n == e.Line,
+		})
+	}
+
+	return e
+}
+
+// findLine returns the 1-based number of the first line matched, or zero.
+func findLine(lines []string, match func(string) bool) int {
+	for i, l := range lines {
+		if match(strings.TrimSpace(l)) {
+			return i + 1
+		}
+	}
+
+	return 0
+}
@@ -1,12 +1,53 @@
 package renderer
 
 import (
+	"code.google.com/p/go.tools/present"
 	"io"
 )
 
-type renderFunc func(io.Writer) error
+// renderFunc renders a version of slides. failure is the error of a later
+// refresh, if any, to be shown over them.
+type renderFunc func(w io.Writer, failure error) error
+
+// docParser parses slides into a document, and returns the files read
+// while parsing.
+type docParser func(r io.Reader, dir, name string, mode present.ParseMode) (*present.Doc, []string, error)
 
 type Renderer interface {
 	Render(w io.Writer) error
+	Rendered() (*Rendered, error)
 	Refresh() error
+	Files() []string
+	Snippets() []Snippet
+	Info() (*DeckInfo, error)
+}
+
+// Parse reads and parses the slides without rendering them, and returns the
+// document with its source decoded. A failure of parsing is *ParseError.
+func Parse(filename, format, encoding string) (*present.Doc, []byte, error) {
+	parse, err := parserOf(format)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := readSource(filename, encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, _, err := parseSource(b, filename, parse)
+	return doc, b, err
+}
+
+// ParseSource parses the decoded slides of filename given in b, such as
+// ones being edited and not saved yet. Files of directives are read next
+// to filename.
+func ParseSource(b []byte, filename, format string) (*present.Doc, error) {
+	parse, err := parserOf(format)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, _, err := parseSource(b, filename, parse)
+	return doc, err
 }
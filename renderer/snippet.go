@@ -0,0 +1,59 @@
+package renderer
+
+import (
+	"code.google.com/p/go.tools/present"
+	"html"
+	"html/template"
+	"regexp"
+)
+
+// Patterns of .code and .play lines, the same as present parses them:
+// CodeRE matches the command with its flags, file and address, HighlightRE
+// the HL marker ending it, and HLCommentRE lines of code marked by
+// "// HL" comments.
+var (
+	CodeRE      = regexp.MustCompile(`\.(code|play)\s+((?:(?:-edit|-numbers)\s+)*)([^\s]+)(?:\s+(.*))?$`)
+	HighlightRE = regexp.MustCompile(`\s+HL([a-zA-Z0-9_]+)?$`)
+	HLCommentRE = regexp.MustCompile(`(.+) // HL(.*)$`)
+)
+
+// Snippet is a runnable snippet of .play in slides.
+type Snippet struct {
+	File string // base name of the file of the snippet
+	Ext  string // extension of the file
+	Body string // program as browsers send it to run
+}
+
+// PlaySnippets returns runnable snippets of the document in order.
+func PlaySnippets(doc *present.Doc) []Snippet {
+	return playSnippets(doc.Sections, nil)
+}
+
+func playSnippets(sections []present.Section, snippets []Snippet) []Snippet {
+	for _, s := range sections {
+		for _, e := range s.Elem {
+			switch t := e.(type) {
+			case present.Section:
+				snippets = playSnippets([]present.Section{t}, snippets)
+			case present.Code:
+				if t.Play {
+					snippets = append(snippets, Snippet{
+						File: t.FileName,
+						Ext:  t.Ext,
+						Body: codeText(t.Text),
+					})
+				}
+			}
+		}
+	}
+
+	return snippets
+}
+
+var tagRE = regexp.MustCompile(`<[^>]*>`)
+
+// codeText returns the text of the code as play.js takes it from the page:
+// the text of every element, including hidden ones around the snippet.
+func codeText(text template.HTML) string {
+	return html.UnescapeString(tagRE.ReplaceAllString(string(text), ""))
+}
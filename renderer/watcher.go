@@ -0,0 +1,128 @@
+package renderer
+
+import (
+	"github.com/scryner/logg"
+	"os"
+	"sync"
+	"time"
+)
+
+// Watcher polls the files a renderer depends on and refreshes the renderer
+// when one of them is modified. Polling needs no platform specific
+// notification service, so it works everywhere.
+type Watcher struct {
+	rend     Renderer
+	interval time.Duration
+
+	mu        sync.Mutex
+	listeners []func(err error)
+	stop      chan struct{}
+	stopOnce  sync.Once
+
+	logger *logg.Logger
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func NewWatcher(rend Renderer, interval time.Duration) *Watcher {
+	return &Watcher{
+		rend:     rend,
+		interval: interval,
+		stop:     make(chan struct{}),
+		logger:   logg.GetDefaultLogger("watcher"),
+	}
+}
+
+// OnRefresh registers a function called after every refresh triggered by
+// the watcher. err is the result of the refresh.
+func (w *Watcher) OnRefresh(f func(err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.listeners = append(w.listeners, f)
+}
+
+// Start begins polling in a new goroutine.
+func (w *Watcher) Start() {
+	go w.loop()
+}
+
+// Stop ends polling. It may be called more than once.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+func (w *Watcher) loop() {
+	stamps := w.snapshot()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := w.snapshot()
+		if sameStamps(stamps, current) {
+			continue
+		}
+
+		w.logger.Infof("change detected; refreshing")
+
+		err := w.rend.Refresh()
+		if err != nil {
+			w.logger.Errorf("failed to refresh: %v", err)
+		}
+
+		// file list may be changed by refreshing
+		stamps = w.snapshot()
+
+		w.mu.Lock()
+		listeners := append([]func(error){}, w.listeners...)
+		w.mu.Unlock()
+
+		for _, f := range listeners {
+			f(err)
+		}
+	}
+}
+
+func (w *Watcher) snapshot() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+
+	for _, fname := range w.rend.Files() {
+		fi, err := os.Stat(fname)
+		if err != nil {
+			stamps[fname] = fileStamp{}
+			continue
+		}
+
+		stamps[fname] = fileStamp{fi.ModTime(), fi.Size(), true}
+	}
+
+	return stamps
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for fname, sa := range a {
+		sb, ok := b[fname]
+		if !ok || sa.exists != sb.exists || sa.size != sb.size || !sa.modTime.Equal(sb.modTime) {
+			return false
+		}
+	}
+
+	return true
+}
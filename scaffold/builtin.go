@@ -0,0 +1,95 @@
+package scaffold
+
+// built-in templates by name; paths are the same as in template directories
+var builtins = map[string]map[string]string{
+	"present": {
+		"{{.Name}}.slide.tmpl": presentSlide,
+		"code/hello.go":        helloGo,
+		"images/":              "",
+		"carousel.json.tmpl":   configJSON,
+	},
+	"markdown": {
+		"{{.Name}}.md.tmpl":  markdownSlide,
+		"images/":            "",
+		"carousel.json.tmpl": configJSON,
+	},
+}
+
+const presentSlide = `{{.Title}}
+{{with .Subtitle}}{{.}}
+{{end}}{{.Date.Format "2 Jan 2006"}}
+{{with .Author}}
+{{.}}
+{{with $.Email}}{{.}}
+{{end}}{{end}}
+* Introduction
+
+- What this talk is about
+- Why it matters
+
+: Lines starting with a colon are speaker notes.
+: They are shown only in the presenter console (/presenter).
+
+* Code
+
+Snippets are kept in the code directory, and .play makes them runnable.
+
+.play code/hello.go
+
+* Images
+
+Put images in the images directory, and show them with .image.
+
+* Thanks
+`
+
+const markdownSlide = `# {{.Title}}
+{{with .Subtitle}}{{.}}
+{{end}}{{.Date.Format "2 Jan 2006"}}
+{{with .Author}}
+{{.}}
+{{with $.Email}}{{.}}
+{{end}}{{end}}
+## Introduction
+
+- What this talk is about
+- Why it matters
+
+: Lines starting with a colon are speaker notes.
+: They are shown only in the presenter console (/presenter).
+
+## Code
+
+A "play" word after the language makes a snippet runnable.
+
+` + "```go play" + `
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("Hello, world!")
+}
+` + "```" + `
+
+## Images
+
+Put images in the images directory, and show them as images of Markdown.
+
+# Thanks
+`
+
+const helloGo = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("Hello, world!")
+}
+`
+
+const configJSON = `{
+  "play": true{{with .Theme}},
+  "theme": {{printf "%q" .}}{{end}}
+}
+`
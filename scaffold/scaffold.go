@@ -0,0 +1,229 @@
+// Package scaffold creates directories of new slides from templates, so
+// that a talk doesn't start by copying and editing another one.
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Default is the template used when none is given.
+const Default = "present"
+
+// TemplateExt marks files of templates which are executed with the Deck.
+// The extension is removed from names of files created, and other files
+// are copied as they are.
+const TemplateExt = ".tmpl"
+
+// Deck describes the new slides, which fills templates.
+type Deck struct {
+	Name     string // of the directory
+	Title    string
+	Subtitle string
+	Author   string
+	Email    string
+	Date     time.Time
+	Theme    string // for the config file; empty for the default
+}
+
+// Template is a set of files of a new directory of slides. A template is
+// either built in the binary or a directory, whose files are copied into
+// the new directory keeping their paths. Names of files may have actions
+// as well, such as {{.Name}}.slide.tmpl.
+type Template struct {
+	Name string
+
+	files map[string][]byte // slash separated path to content; directories end with "/"
+}
+
+// Builtins returns names of the built-in templates.
+func Builtins() []string {
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// List returns names of templates in dir, which is the directory of the
+// team's templates. It is empty if dir doesn't exist.
+func List(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, fi := range fis {
+		if fi.IsDir() && !strings.HasPrefix(fi.Name(), ".") {
+			names = append(names, fi.Name())
+		}
+	}
+
+	return names
+}
+
+// Load returns the template of the name, which is a built-in template, one
+// in the directory dir, or a directory itself.
+func Load(name, dir string) (*Template, error) {
+	if name == "" {
+		name = Default
+	}
+
+	if files, ok := builtins[name]; ok {
+		t := &Template{
+			Name:  name,
+			files: make(map[string][]byte),
+		}
+		for p, s := range files {
+			t.files[p] = []byte(s)
+		}
+
+		return t, nil
+	}
+
+	candidates := []string{name}
+	if dir != "" && !filepath.IsAbs(name) {
+		candidates = append([]string{filepath.Join(dir, name)}, candidates...)
+	}
+
+	for _, c := range candidates {
+		if fi, err := os.Stat(c); err == nil && fi.IsDir() {
+			return loadDir(c)
+		}
+	}
+
+	names := append(Builtins(), List(dir)...)
+	return nil, fmt.Errorf("template '%s' is neither one of %s nor a directory", name, strings.Join(names, ", "))
+}
+
+func loadDir(dir string) (*Template, error) {
+	t := &Template{
+		Name:  filepath.Base(dir),
+		files: make(map[string][]byte),
+	}
+
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+
+		// skip hidden directories such as .git
+		if fi.IsDir() && strings.HasPrefix(fi.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if fi.IsDir() {
+			t.files[rel+"/"] = nil
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		t.files[rel] = b
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Create creates the directory target with files of the template, and
+// returns paths of files and directories created. target must not exist, or be empty.
+func (t *Template) Create(target string, deck Deck) ([]string, error) {
+	if fis, err := ioutil.ReadDir(target); err == nil && len(fis) > 0 {
+		return nil, fmt.Errorf("the directory is not empty")
+	}
+
+	var paths []string
+	for p := range t.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return nil, err
+	}
+
+	var created []string
+	for _, p := range paths {
+		nb, err := t.execute(p, []byte(p), deck)
+		if err != nil {
+			return created, err
+		}
+		name := string(nb)
+
+		if strings.HasSuffix(name, "/") {
+			dir := filepath.Join(target, filepath.FromSlash(name))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return created, err
+			}
+
+			created = append(created, dir+string(filepath.Separator))
+			continue
+		}
+
+		b := t.files[p]
+		if path.Ext(name) == TemplateExt {
+			name = strings.TrimSuffix(name, TemplateExt)
+			if b, err = t.execute(p, b, deck); err != nil {
+				return created, err
+			}
+		}
+
+		file := filepath.Join(target, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return created, err
+		}
+		if err := ioutil.WriteFile(file, b, 0644); err != nil {
+			return created, err
+		}
+
+		created = append(created, file)
+	}
+
+	return created, nil
+}
+
+// execute executes the text of the file p as a template with the deck.
+func (t *Template) execute(p string, text []byte, deck Deck) ([]byte, error) {
+	tmpl, err := template.New(p).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("while parsing %s of template '%s': %v", p, t.Name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, deck); err != nil {
+		return nil, fmt.Errorf("while executing %s of template '%s': %v", p, t.Name, err)
+	}
+
+	return buf.Bytes(), nil
+}
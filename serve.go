@@ -0,0 +1,295 @@
+package main
+
+import (
+	"carousel/config"
+	"carousel/playground"
+	"carousel/renderer"
+	"carousel/server"
+	"carousel/static"
+	"flag"
+	"fmt"
+	"github.com/scryner/logg"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var serveCommand = &command{
+	name:  "serve",
+	args:  "filepath|directory",
+	short: "serve slides, reloading browsers on changes (default)",
+	long: `
+Serve serves the slides of a file, or every slide in a directory, until it
+is interrupted. Audience following the presenter opens the slides, and the
+presenter opens the presenter console whose address is logged.
+
+With -P, snippets of .play run on this machine through a WebSocket, or
+through /compile as the Go playground does with -R, each in a temporary
+directory within playLimits of the config: timeout, cpu, memoryMB,
+openFiles and outputKB, and runs at once, beyond which they wait. Limits
+of CPU, memory and open files work only on Linux.
+
+Snippets of Go are run by the go tool, and ones of other languages by
+runners enabled by runners of the config: python, shell, node and rust.
+The runner is selected by the shebang of the snippet if it has one, and
+otherwise by its extension, or the language of fenced code in Markdown,
+with -R as well.
+
+Snippets of Go are built in the background when slides are served and
+refreshed, and their binaries are kept in playCache of the config by
+hashes of their source, the version of Go and the race flag, so that Run
+only executes them. They are built within playLimits as builds before
+runs are, taking slots of runs. Failures of the builds are logged. The
+cache survives restarts, and lint fills it as well.
+
+With -replay, which implies -P, nothing is run, and output recorded by the
+record command next to the slides is streamed with its timing instead,
+labeled as replayed.
+
+Serve is run when no command is given.`,
+	flags: serveFlags,
+	run:   runServe,
+}
+
+var servePrintConfig bool
+
+func serveFlags(fs *flag.FlagSet) {
+	fs.IntVar(&cfg.Port, "p", cfg.Port, "listen port")
+	fs.StringVar(&cfg.Bind, "bind", cfg.Bind, "address to listen on (default is every address)")
+	fs.BoolVar(&cfg.Gzip, "z", cfg.Gzip, "whether gzip supported or not")
+	fs.BoolVar(&cfg.Launch, "l", cfg.Launch, "launch local web browser immediately")
+	fs.BoolVar(&cfg.Watch, "w", cfg.Watch, "watch input files and reload browsers on change")
+	fs.Var(&cfg.DrainTimeout, "drain", "time to wait for connections to finish at shutdown")
+	fs.BoolVar(&cfg.Play, "P", cfg.Play, "enable go playground")
+	fs.BoolVar(&cfg.RemotePlay, "R", cfg.RemotePlay, "go playground by HTTP (/compile) instead of WebSocket")
+	fs.BoolVar(&cfg.Replay, "replay", cfg.Replay, "replay output of snippets recorded by record instead of running them")
+	fs.StringVar(&cfg.PlayCache, "cache", cfg.PlayCache, "directory keeping binaries of snippets (empty not to keep them)")
+	fs.BoolVar(&servePrintConfig, "print-config", false, "print the effective config and exit")
+
+	inputFlags(fs)
+	themeFlag(fs)
+	logFlags(fs)
+}
+
+func runServe(cmd *command, fs *flag.FlagSet) int {
+	inputPath := fs.Arg(0)
+
+	if err := loadConfig(fs, inputPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	if servePrintConfig {
+		os.Stdout.Write(cfg.JSON())
+		return exitOK
+	}
+
+	switch {
+	case fs.NArg() == 0:
+		return cmd.usageError("no slides given")
+	case fs.NArg() > 1:
+		return cmd.usageError("too many arguments")
+	}
+
+	return serve(inputPath)
+}
+
+// serve serves the slides of inputPath until interrupted, and returns the
+// exit code.
+func serve(inputPath string) int {
+	cleanup := setupLogging()
+	defer cleanup()
+
+	if err := checkInput(); err != nil {
+		logger.Errorf("%v", err)
+		return exitFailure
+	}
+
+	if cfg.Theme != "" {
+		logger.Infof("Theme '%s' for all slides", cfg.Theme)
+	}
+
+	// initializing static file list
+	staticFiles := make(map[string]server.StaticContent)
+	staticFiles["/static/slides.js"] = server.StaticContent{Mine: "text/javascript", Content: static.Slides_js}
+	staticFiles["/static/print.css"] = server.StaticContent{Mine: "text/css", Content: static.Print_css}
+	staticFiles["/static/styles.css"] = server.StaticContent{Mine: "text/css", Content: static.Styles_css}
+	staticFiles["/static/sync.js"] = server.StaticContent{Mine: "text/javascript", Content: static.Sync_js}
+	staticFiles["/presenter"] = server.StaticContent{Mine: "text/html", Content: static.Presenter_html}
+
+	var pg *playground.Playground
+
+	if cfg.Replay {
+		cfg.Play = true
+	}
+
+	if cfg.Play {
+		logger.Infof("Go playground enabled")
+
+		// nothing is built to replay
+		var cache *playground.Cache
+		if cfg.PlayCache != "" && !cfg.Replay {
+			cache = playground.NewCache(cfg.PlayCache, playLimits(cfg.PlayLimits))
+		}
+
+		var err error
+		if pg, err = playground.New(playLimits(cfg.PlayLimits), cfg.Runners, cache); err != nil {
+			logger.Errorf("%v", err)
+			return exitFailure
+		}
+
+		if cfg.RemotePlay {
+			logger.Infof("\t: to local playground by HTTP")
+			staticFiles["/static/play.js"] = server.StaticContent{Mine: "text/javascript", Content: static.Play_js + "\ninitPlayground(new HTTPTransport());\n"}
+		} else {
+			logger.Infof("\t: to local playground by WebSocket")
+			staticFiles["/static/play.js"] = server.StaticContent{Mine: "text/javascript", Content: static.Play_js + "\ninitPlayground(new SocketTransport());\n"}
+		}
+
+		if cfg.Replay {
+			files, err := recordingsFiles(inputPath)
+			if err != nil {
+				logger.Errorf("%v", err)
+				return exitFailure
+			}
+			pg.Replay(files)
+
+			logger.Infof("\t: replaying output recorded next to slides instead of running snippets")
+			for _, file := range files {
+				if _, err := os.Stat(file); err == nil {
+					logger.Infof("\t: recorded in '%s'", file)
+				}
+			}
+		} else {
+			runners, missing := pg.Runners()
+			logger.Infof("\t: running snippets of %s", strings.Join(runners, ", "))
+			for _, command := range missing {
+				logger.Warnf("\t: %s is not found", command)
+			}
+
+			if cache != nil {
+				logger.Infof("\t: binaries of snippets kept in '%s'", cache.Dir())
+			}
+
+			logger.Infof("\t: limited to %v", pg.Limits())
+			if !playground.RlimitsSupported {
+				logger.Warnf("\t: limits of CPU, memory and open files are not supported on %s", runtime.GOOS)
+			}
+		}
+	} else {
+		logger.Infof("Go playground disabled")
+	}
+
+	if cfg.Watch {
+		staticFiles["/static/reload.js"] = server.StaticContent{Mine: "text/javascript", Content: static.Reload_js}
+	}
+
+	var watchInterval time.Duration
+	if cfg.Watch {
+		logger.Infof("Live reload enabled")
+		watchInterval = _DEFAULT_WATCH_INTERVAL
+	}
+
+	newRenderer := func(filename string) renderer.Renderer {
+		if formatOf(filename, cfg.Format) == renderer.FormatMarkdown {
+			return renderer.NewMarkdownRenderer(filename, cfg.Theme, cfg.Encoding, cfg.Play, cfg.Watch)
+		}
+
+		return renderer.NewFileRenderer(filename, cfg.Theme, cfg.Encoding, cfg.Play, cfg.Watch)
+	}
+
+	// initializing server
+	srv := server.NewServer(net.JoinHostPort(cfg.Bind, strconv.Itoa(cfg.Port)), cfg.Gzip, watchInterval, staticFiles)
+
+	if pg != nil {
+		srv.ServePlayground(pg)
+	}
+
+	fi, err := os.Stat(inputPath)
+	if err != nil {
+		logger.Errorf("can't open '%s': %v", inputPath, err)
+		return exitFailure
+	}
+
+	if fi.IsDir() {
+		logger.Infof("Serving slides in '%s'", inputPath)
+		srv.ServeDirectory(inputPath, newRenderer)
+
+		logger.Infof("Presenter console of each slide is on its path + presenter?token=%s", srv.SyncToken())
+	} else {
+		srv.ServeDeck(inputPath, newRenderer(inputPath))
+
+		logger.Infof("Presenter console is on http://%s/presenter?token=%s", localAddr(), srv.SyncToken())
+		logger.Infof("\t: audience following the presenter opens http://<address>:%d/", cfg.Port)
+	}
+
+	// trying to launch web browser
+	if cfg.Launch {
+		go tryLaunchWebBrowser()
+	}
+
+	// print logo
+	fmt.Printf(asciiLogo, VERSION)
+
+	// starting server
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Start()
+	}()
+
+	sigc := make(chan os.Signal, 2)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-errc:
+		logger.Errorf("Failed to start server: %v", err)
+		return exitFailure
+	case sig := <-sigc:
+		logger.Infof("%v received; shutting down (again to quit at once)", sig)
+	}
+
+	go func() {
+		<-sigc
+		logg.Flush()
+		os.Exit(1)
+	}()
+
+	if err := srv.Shutdown(time.Duration(cfg.DrainTimeout)); err != nil {
+		logger.Warnf("Shutdown: %v", err)
+	}
+
+	logger.Infof("Bye")
+	return exitOK
+}
+
+func playLimits(l config.Limits) playground.Limits {
+	return playground.Limits{
+		Timeout:   time.Duration(l.Timeout),
+		CPU:       time.Duration(l.CPU),
+		Memory:    int64(l.MemoryMB) << 20,
+		OpenFiles: l.OpenFiles,
+		Output:    int64(l.OutputKB) << 10,
+		Runs:      l.Runs,
+	}
+}
+
+// recordingsFiles returns files of recordings of slides of inputPath, which
+// may not exist yet.
+func recordingsFiles(inputPath string) ([]string, error) {
+	slides, err := deckFiles([]string{inputPath})
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, file := range slides {
+		files = append(files, playground.RecordingsFile(file))
+	}
+
+	return files, nil
+}
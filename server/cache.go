@@ -0,0 +1,117 @@
+package server
+
+import (
+	"carousel/renderer"
+	"crypto/sha1"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serveRendered serves an output kept by its ETag, answering conditional
+// requests with 304 and giving the gzipped copy to clients accepting it.
+// The gzipped copy has its own ETag, as it is another representation.
+func (srv *Server) serveRendered(w http.ResponseWriter, r *http.Request, contentType string, rendered *renderer.Rendered) {
+	gzipped := srv.enableGzip && negotiateEncoding(r) == "gzip"
+
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	if gzipped {
+		h.Set("ETag", encodedETag(rendered.ETag, "gzip"))
+	} else {
+		h.Set("ETag", rendered.ETag)
+	}
+	if !rendered.ModTime.IsZero() {
+		h.Set("Last-Modified", rendered.ModTime.UTC().Format(http.TimeFormat))
+	}
+	// browsers have to ask whether slides changed every time they reload
+	h.Set("Cache-Control", "no-cache")
+	addVary(h, "Accept-Encoding")
+
+	if notModified(r, rendered) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if gzipped {
+		h.Set("Content-Encoding", "gzip")
+	}
+
+	if r.Method == "HEAD" {
+		return
+	}
+
+	if gzipped {
+		w.Write(rendered.Gzipped)
+		return
+	}
+
+	// other encodings are left to the compressing handler
+	w.Write(rendered.Body)
+}
+
+// encodedETag returns the ETag of the representation of the content
+// compressed by the encoding, telling it apart from the identity one.
+func encodedETag(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// notModified tells whether the client already has the output, in any of
+// its encodings. ETags take precedence over modification times as HTTP
+// says.
+func notModified(r *http.Request, rendered *renderer.Rendered) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, etag := range strings.Split(inm, ",") {
+			etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+			if etag == "*" || etag == rendered.ETag {
+				return true
+			}
+			for _, encoding := range compressEncodings {
+				if etag == encodedETag(rendered.ETag, encoding) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if rendered.ModTime.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	// the header has no fraction of a second
+	return !rendered.ModTime.Truncate(time.Second).After(t)
+}
+
+// cachedStatic returns the kept output of the static content, preparing
+// it at the first request.
+func (srv *Server) cachedStatic(key string, body []byte) (*renderer.Rendered, error) {
+	srv.mu.Lock()
+	rendered, ok := srv.staticCache[key]
+	srv.mu.Unlock()
+
+	if ok {
+		return rendered, nil
+	}
+
+	sum := sha1.Sum(body)
+	rendered, err := renderer.NewRendered(body, sum[:], srv.startTime)
+	if err != nil {
+		return nil, err
+	}
+
+	srv.mu.Lock()
+	srv.staticCache[key] = rendered
+	srv.mu.Unlock()
+
+	return rendered, nil
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MinCompressSize is the size of responses below which compressing costs
+// more than it saves.
+const MinCompressSize = 1024
+
+// encodings the handler compresses with, in the order of preference
+var compressEncodings = []string{"gzip", "deflate"}
+
+// types of contents worth compressing besides text/*
+var compressibleTypes = map[string]bool{
+	"application/javascript":   true,
+	"application/x-javascript": true,
+	"application/json":         true,
+	"application/xml":          true,
+	"application/xhtml+xml":    true,
+	"image/svg+xml":            true,
+}
+
+type compressHandler struct {
+	handler http.Handler
+	minSize int
+}
+
+// NewCompressHandler returns a handler compressing responses of h by the
+// encoding the client accepts most. Only textual contents of minSize bytes
+// or more are compressed; responses already encoded by h, partial ones and
+// ones to HEAD requests are left as they are.
+func NewCompressHandler(h http.Handler, minSize int) http.Handler {
+	return &compressHandler{
+		handler: h,
+		minSize: minSize,
+	}
+}
+
+func (ch *compressHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	encoding := negotiateEncoding(r)
+
+	// ranges are offsets into the uncompressed content
+	if r.Method == "HEAD" || r.Header.Get("Range") != "" {
+		encoding = ""
+	}
+
+	cw := &compressResponseWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		minSize:        ch.minSize,
+	}
+	defer cw.close()
+
+	ch.handler.ServeHTTP(cw, r)
+}
+
+// negotiateEncoding returns the compression the client accepts with the
+// highest quality, or an empty string if it accepts none.
+func negotiateEncoding(r *http.Request) string {
+	header := r.Header.Get("Accept-Encoding")
+	if header == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				q = v
+			}
+		}
+
+		qualities[coding] = q
+	}
+
+	var (
+		best  string
+		bestQ float64
+	)
+	for _, coding := range compressEncodings {
+		q, ok := qualities[coding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+
+	return best
+}
+
+// isCompressible tells whether contents of the type get smaller by
+// compressing. Images other than SVG, videos and archives are compressed
+// already.
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "text/") || compressibleTypes[mediaType]
+}
+
+// addVary adds the field to the Vary header unless it is there already.
+func addVary(h http.Header, field string) {
+	for _, v := range h["Vary"] {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", field)
+}
+
+// compressResponseWriter decides whether to compress when the header is
+// written. Until then, a body without Content-Length is held to see if it
+// reaches the minimum size.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string // accepted by the client, or empty
+	minSize  int
+
+	code    int  // status held with the body
+	decided bool // and the header is written to the underlying writer
+	buf     []byte
+	cw      io.WriteCloser // nil unless compressing
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+
+	if code < http.StatusOK {
+		// informational responses come before the final one
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.code = code
+
+	h := w.Header()
+
+	switch {
+	case h.Get("Content-Encoding") != "":
+		// the handler encoded the body by itself
+		w.decide(false)
+	case code == http.StatusNoContent || code == http.StatusNotModified:
+		w.decide(false)
+	case code == http.StatusPartialContent || h.Get("Content-Range") != "":
+		w.decide(false)
+	}
+
+	if w.decided {
+		return
+	}
+
+	if isCompressible(h.Get("Content-Type")) {
+		addVary(h, "Accept-Encoding")
+	} else if h.Get("Content-Type") != "" {
+		w.decide(false)
+		return
+	}
+
+	if w.encoding == "" {
+		w.decide(false)
+		return
+	}
+
+	if cl := h.Get("Content-Length"); cl != "" {
+		n, err := strconv.Atoi(cl)
+		w.decide(err == nil && n >= w.minSize && h.Get("Content-Type") != "")
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+
+		if err := w.decideByBody(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decideByBody decides by the body held so far, which is long enough to
+// be compressed, and writes it.
+func (w *compressResponseWriter) decideByBody() error {
+	h := w.Header()
+
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+		if !isCompressible(h.Get("Content-Type")) {
+			w.decide(false)
+		} else {
+			addVary(h, "Accept-Encoding")
+		}
+	}
+
+	if !w.decided {
+		w.decide(true)
+	}
+
+	buf := w.buf
+	w.buf = nil
+
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// decide writes the header to the underlying writer, starting compression
+// if compress is true.
+func (w *compressResponseWriter) decide(compress bool) {
+	w.decided = true
+
+	if compress {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+
+		// a strong ETag belongs to the identity content only
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", encodedETag(etag, w.encoding))
+		}
+
+		switch w.encoding {
+		case "gzip":
+			w.cw = gzip.NewWriter(w.ResponseWriter)
+		case "deflate":
+			// deflate of HTTP is the zlib format
+			w.cw = zlib.NewWriter(w.ResponseWriter)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.code)
+}
+
+// close writes what is held, and ends compression.
+func (w *compressResponseWriter) close() {
+	if w.code == 0 {
+		// the handler wrote nothing, or hijacked the connection
+		return
+	}
+
+	if !w.decided {
+		// too short to be compressed
+		w.decide(false)
+		if len(w.buf) > 0 {
+			w.ResponseWriter.Write(w.buf)
+		}
+		w.buf = nil
+	}
+
+	if w.cw != nil {
+		w.cw.Close()
+	}
+}
+
+func (w *compressResponseWriter) Flush() {
+	if w.code != 0 && !w.decided {
+		w.decideByBody()
+	}
+
+	if f, ok := w.cw.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
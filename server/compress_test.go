@@ -0,0 +1,345 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip, deflate", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"GZIP", "gzip"},
+		{"br", ""},
+		{"identity", ""},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0.1", "deflate"},
+		{"gzip;q=0", ""},
+		{"gzip; q=0 , deflate;q=0", ""},
+		{"*", "gzip"},
+		{"*;q=0", ""},
+		{"*, gzip;q=0", "deflate"},
+		{"deflate;q=0.5, *;q=0.8", "gzip"},
+		{"identity;q=0", ""},
+		{"gzip, identity;q=0", "gzip"},
+		{"identity;q=0, *;q=0.1", "gzip"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept-Encoding", test.accept)
+		}
+
+		if got := negotiateEncoding(r); got != test.want {
+			t.Errorf("Accept-Encoding %q: got %q; want %q", test.accept, got, test.want)
+		}
+	}
+}
+
+var (
+	longText  = strings.Repeat("carousel slides ", 200)
+	shortText = "short"
+)
+
+// serveCompressed serves the request by the handler behind the compressing
+// one, and returns the response with its body decoded.
+func serveCompressed(t *testing.T, h http.HandlerFunc, r *http.Request) (*http.Response, string) {
+	rec := httptest.NewRecorder()
+	NewCompressHandler(h, MinCompressSize).ServeHTTP(rec, r)
+
+	resp := rec.Result()
+
+	var body io.Reader = resp.Body
+	switch resp.Header.Get("Content-Encoding") {
+	case "gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = zr
+	case "deflate":
+		zr, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = zr
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp, string(b)
+}
+
+func textHandler(contentType, body string, withLength bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if withLength {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		io.WriteString(w, body)
+	}
+}
+
+func TestCompressHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		accept     string
+		wantEnc    string
+		wantVary   bool
+		wantBody   string
+		wantCode   int
+		wantLength int // of Content-Length if kept; 0 if dropped
+	}{
+		{
+			name:     "gzip",
+			handler:  textHandler("text/html; charset=utf-8", longText, false),
+			accept:   "gzip, deflate",
+			wantEnc:  "gzip",
+			wantVary: true,
+			wantBody: longText,
+		},
+		{
+			name:     "deflate preferred",
+			handler:  textHandler("text/css", longText, false),
+			accept:   "gzip;q=0.2, deflate",
+			wantEnc:  "deflate",
+			wantVary: true,
+			wantBody: longText,
+		},
+		{
+			name:     "content length dropped",
+			handler:  textHandler("application/javascript", longText, true),
+			accept:   "gzip",
+			wantEnc:  "gzip",
+			wantVary: true,
+			wantBody: longText,
+		},
+		{
+			name:       "content length below the minimum",
+			handler:    textHandler("text/plain", shortText, true),
+			accept:     "gzip",
+			wantVary:   true,
+			wantBody:   shortText,
+			wantLength: len(shortText),
+		},
+		{
+			name:     "body below the minimum",
+			handler:  textHandler("text/plain", shortText, false),
+			accept:   "gzip",
+			wantVary: true,
+			wantBody: shortText,
+		},
+		{
+			name:     "refused by q=0",
+			handler:  textHandler("text/plain", longText, false),
+			accept:   "gzip;q=0, deflate;q=0",
+			wantVary: true,
+			wantBody: longText,
+		},
+		{
+			name:     "any encoding",
+			handler:  textHandler("text/plain", longText, false),
+			accept:   "*",
+			wantEnc:  "gzip",
+			wantVary: true,
+			wantBody: longText,
+		},
+		{
+			name:     "identity refused",
+			handler:  textHandler("text/plain", longText, false),
+			accept:   "identity;q=0",
+			wantVary: true,
+			wantBody: longText,
+		},
+		{
+			name:     "no Accept-Encoding",
+			handler:  textHandler("text/plain", longText, false),
+			wantVary: true,
+			wantBody: longText,
+		},
+		{
+			name:       "not compressible",
+			handler:    textHandler("image/png", longText, true),
+			accept:     "gzip",
+			wantBody:   longText,
+			wantLength: len(longText),
+		},
+		{
+			name:     "detected type",
+			handler:  textHandler("", longText, false),
+			accept:   "gzip",
+			wantEnc:  "gzip",
+			wantVary: true,
+			wantBody: longText,
+		},
+		{
+			name:     "detected type not compressible",
+			handler:  textHandler("", "\x89PNG\r\n\x1a\n"+longText, false),
+			accept:   "gzip",
+			wantBody: "\x89PNG\r\n\x1a\n" + longText,
+		},
+		{
+			name: "encoded already",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("Content-Encoding", "br")
+				io.WriteString(w, longText)
+			},
+			accept:   "gzip",
+			wantEnc:  "br",
+			wantBody: longText,
+		},
+		{
+			name: "partial content",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Content-Range", "bytes 0-99/3200")
+				w.WriteHeader(http.StatusPartialContent)
+				io.WriteString(w, longText[:100])
+			},
+			accept:   "gzip",
+			wantBody: longText[:100],
+			wantCode: http.StatusPartialContent,
+		},
+		{
+			name: "not modified",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusNotModified)
+			},
+			accept:   "gzip",
+			wantCode: http.StatusNotModified,
+		},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept-Encoding", test.accept)
+		}
+
+		resp, body := serveCompressed(t, test.handler, r)
+
+		wantCode := test.wantCode
+		if wantCode == 0 {
+			wantCode = http.StatusOK
+		}
+		if resp.StatusCode != wantCode {
+			t.Errorf("%s: status %d; want %d", test.name, resp.StatusCode, wantCode)
+		}
+		if enc := resp.Header.Get("Content-Encoding"); enc != test.wantEnc {
+			t.Errorf("%s: Content-Encoding %q; want %q", test.name, enc, test.wantEnc)
+		}
+		if vary := resp.Header.Get("Vary") == "Accept-Encoding"; vary != test.wantVary {
+			t.Errorf("%s: Vary %q; want Accept-Encoding %v", test.name, resp.Header.Get("Vary"), test.wantVary)
+		}
+		if body != test.wantBody {
+			t.Errorf("%s: body of %d bytes differs from %d bytes written", test.name, len(body), len(test.wantBody))
+		}
+
+		cl := resp.Header.Get("Content-Length")
+		switch {
+		case test.wantLength == 0 && cl != "":
+			t.Errorf("%s: Content-Length %s is kept", test.name, cl)
+		case test.wantLength != 0 && cl != strconv.Itoa(test.wantLength):
+			t.Errorf("%s: Content-Length %q; want %d", test.name, cl, test.wantLength)
+		}
+	}
+}
+
+func TestCompressHandlerPassesThrough(t *testing.T) {
+	h := textHandler("text/html", longText, true)
+
+	// ranges are offsets into the uncompressed content
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("Range", "bytes=0-99")
+
+	resp, body := serveCompressed(t, h, r)
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+		t.Errorf("request of a range: Content-Encoding %q", enc)
+	}
+	if body != longText {
+		t.Errorf("request of a range: body differs")
+	}
+
+	r = httptest.NewRequest("HEAD", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	NewCompressHandler(h, MinCompressSize).ServeHTTP(rec, r)
+
+	resp = rec.Result()
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+		t.Errorf("HEAD: Content-Encoding %q", enc)
+	}
+	if cl := resp.Header.Get("Content-Length"); cl != strconv.Itoa(len(longText)) {
+		t.Errorf("HEAD: Content-Length %q; want %d", cl, len(longText))
+	}
+}
+
+func TestCompressHandlerETag(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"abc"`)
+		io.WriteString(w, longText)
+	}
+
+	for _, test := range []struct {
+		accept, want string
+	}{
+		{"gzip", `"abc-gzip"`},
+		{"deflate", `"abc-deflate"`},
+		{"", `"abc"`},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept-Encoding", test.accept)
+		}
+
+		resp, _ := serveCompressed(t, h, r)
+		if etag := resp.Header.Get("ETag"); etag != test.want {
+			t.Errorf("Accept-Encoding %q: ETag %s; want %s", test.accept, etag, test.want)
+		}
+	}
+}
+
+func TestCompressHandlerWritesInPieces(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		for i := 0; i < len(longText); i += 100 {
+			io.WriteString(w, longText[i:i+100])
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	resp, body := serveCompressed(t, h, r)
+	if enc := resp.Header.Get("Content-Encoding"); enc != "gzip" {
+		t.Errorf("Content-Encoding %q; want gzip", enc)
+	}
+	if body != longText {
+		t.Errorf("body differs from what is written")
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"carousel/playground"
+	"carousel/renderer"
+	"net/http"
+	"path/filepath"
+	"sync"
+)
+
+// deck serves a slide under a path prefix. Paths of the deck, such as
+// /reload and /presenter, and files the slide refers are relative to the
+// prefix, so several decks can be served by one server.
+type deck struct {
+	srv         *Server
+	prefix      string // "/" or "/name/"
+	workingPath string
+	rend        renderer.Renderer
+	reload      *reloadHub
+	sync        *syncHub
+
+	watchOnce sync.Once
+	watcher   *renderer.Watcher
+}
+
+func newDeck(srv *Server, prefix, workingPath string, rend renderer.Renderer) *deck {
+	return &deck{
+		srv:         srv,
+		prefix:      prefix,
+		workingPath: workingPath,
+		rend:        rend,
+		reload:      newReloadHub(),
+		sync:        newSyncHub(srv.syncToken),
+	}
+}
+
+// serveHTTP handles a request whose path is relative to the prefix of the
+// deck; path always starts with "/".
+func (d *deck) serveHTTP(w http.ResponseWriter, r *http.Request, path string) {
+	switch path {
+	case "/":
+		d.handleSlides(w, r)
+
+	case "/refresh":
+		d.handleRefresh(w, r)
+
+	case "/reload":
+		d.srv.trackWebsocket(d.reload.handler).ServeHTTP(w, r)
+
+	case "/sync":
+		d.srv.trackWebsocket(d.sync.handler).ServeHTTP(w, r)
+
+	default:
+		if _, ok := d.srv.staticFiles[path]; ok {
+			d.srv.serveStaticFile(w, r, path)
+			return
+		}
+
+		fpath := filepath.Join(d.workingPath, filepath.FromSlash(path))
+		http.ServeFile(w, r, fpath)
+	}
+}
+
+// watch starts watching files of the deck if live reload is enabled. It is
+// deferred until the deck is opened, not to render every deck of a
+// directory at start.
+func (d *deck) watch() {
+	if d.srv.watchInterval <= 0 {
+		return
+	}
+
+	d.watchOnce.Do(func() {
+		// render once to know which files the slides depend on
+		if err := d.rend.Refresh(); err != nil {
+			d.srv.logger.Errorf("failed to render '%s': %v", d.prefix, err)
+		} else {
+			d.prebuild()
+		}
+
+		d.watcher = renderer.NewWatcher(d.rend, d.srv.watchInterval)
+		d.watcher.OnRefresh(func(err error) {
+			if err == nil {
+				d.prebuild()
+			}
+			d.notifyReload()
+		})
+		d.watcher.Start()
+	})
+}
+
+func (d *deck) close() {
+	if d.watcher != nil {
+		d.watcher.Stop()
+	}
+}
+
+// prebuild builds snippets of .play in the background if the playground
+// caches them, so that they start at once when run.
+func (d *deck) prebuild() {
+	pg := d.srv.getPlayground()
+	if pg == nil || pg.Cache() == nil {
+		return
+	}
+
+	var sources []playground.Source
+	for _, s := range d.rend.Snippets() {
+		sources = append(sources, playground.Source{Name: s.File, Body: s.Body, Ext: s.Ext})
+	}
+
+	pg.Prebuild(d.prefix, sources)
+}
+
+func (d *deck) notifyReload() {
+	d.srv.logger.Debugf("notifying clients of '%s' to reload", d.prefix)
+	d.reload.broadcast()
+}
+
+func (d *deck) handleSlides(w http.ResponseWriter, r *http.Request) {
+	d.watch()
+
+	rendered, err := d.rend.Rendered()
+	if err != nil {
+		d.srv.logger.Errorf("failed to render '%s': %v", d.prefix, err)
+		d.handleError(w, err)
+		return
+	}
+
+	d.srv.serveRendered(w, r, "text/html; charset=utf-8", rendered)
+}
+
+func (d *deck) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	err := d.rend.Refresh()
+	if err != nil {
+		d.handleError(w, err)
+		return
+	}
+
+	d.prebuild()
+	d.notifyReload()
+
+	http.Redirect(w, r, d.prefix, http.StatusFound)
+}
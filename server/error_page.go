@@ -0,0 +1,34 @@
+package server
+
+import (
+	"carousel/renderer"
+	"carousel/templates"
+	"html/template"
+	"net/http"
+)
+
+var errorTemplate = template.Must(template.New("error_tmpl").Parse(templates.Error_tmpl))
+
+// handleError shows why the slides can't be rendered. With live reload,
+// the page reloads itself once the slides are fixed.
+func (d *deck) handleError(w http.ResponseWriter, err error) {
+	pe, ok := err.(*renderer.ParseError)
+	if !ok {
+		pe = &renderer.ParseError{
+			File:    d.rend.Files()[0],
+			Message: err.Error(),
+		}
+	}
+
+	data := struct {
+		*renderer.ParseError
+		LiveReload bool
+	}{pe, d.srv.watchInterval > 0}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	if err := errorTemplate.ExecuteTemplate(w, "error", data); err != nil {
+		d.srv.logger.Errorf("failed to render error page: %v", err)
+	}
+}
@@ -1,45 +1,63 @@
 package server
 
 import (
-	"bufio"
 	"compress/gzip"
-	"fmt"
+	"context"
 	"io"
-	"net"
 	"net/http"
-	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 type gzipHttpServer struct {
-	port       int
+	addr       string
 	enableGzip bool
 
 	serveHTTP func(w http.ResponseWriter, r *http.Request)
-}
 
-//var _server *http.Server // preventing unwilled garbage collection
+	serverMu sync.Mutex
+	server   *http.Server // nil until started
+	stopped  bool
+}
 
+// start serves until stop is called, and then returns nil.
 func (srv *gzipHttpServer) start() error {
-	_server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", srv.port),
-		Handler: http.HandlerFunc(getHandler(srv)),
+	srv.serverMu.Lock()
+	if srv.stopped {
+		srv.serverMu.Unlock()
+		return nil
+	}
+	srv.server = &http.Server{
+		Addr:    srv.addr,
+		Handler: srv.handler(),
 	}
+	srv.serverMu.Unlock()
 
-	return _server.ListenAndServe()
-}
+	err := srv.server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
 
-type gzipResponseWriter struct {
-	io.Writer
-	http.ResponseWriter
+	return err
 }
 
-func (w gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
-}
+// stop closes the listener, and waits for requests in flight until the
+// timeout. Hijacked connections such as websockets are not waited for.
+func (srv *gzipHttpServer) stop(timeout time.Duration) error {
+	srv.serverMu.Lock()
+	srv.stopped = true
+	server := srv.server
+	srv.serverMu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-func (w gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return w.ResponseWriter.(http.Hijacker).Hijack()
+	return server.Shutdown(ctx)
 }
 
 // gzip.Reader.Close() does not close underlying reader, so we need to close at the end.
@@ -61,8 +79,15 @@ func (r gzipBodyReader) Close() error {
 	return r.ReadCloser.Close()
 }
 
-func getHandler(srv *gzipHttpServer) func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
+// handler decodes gzipped request bodies, and compresses responses if
+// enabled.
+func (srv *gzipHttpServer) handler() http.Handler {
+	var h http.Handler = http.HandlerFunc(srv.serveHTTP)
+	if srv.enableGzip {
+		h = NewCompressHandler(h, MinCompressSize)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// handing content-encoding: gzip
 		if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
 			compressedBody := r.Body
@@ -77,26 +102,6 @@ func getHandler(srv *gzipHttpServer) func(w http.ResponseWriter, r *http.Request
 			r.Body = newBody
 		}
 
-		// handling accept-decooding: gzip
-		if !srv.enableGzip || !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || !isGzipAble(r) {
-			srv.serveHTTP(w, r)
-		} else {
-			w.Header().Add("Content-Encoding", "gzip")
-			gz := gzip.NewWriter(w)
-			defer gz.Close()
-			srv.serveHTTP(gzipResponseWriter{Writer: gz, ResponseWriter: w}, r)
-		}
-	}
-}
-
-func isGzipAble(r *http.Request) bool {
-	ext := filepath.Ext(r.RequestURI)
-	ext = strings.ToUpper(ext)
-
-	switch ext {
-	case ".PNG", ".JPG", ".JPEG", ".GIF", ".ZIP":
-		return false
-	}
-
-	return true
+		h.ServeHTTP(w, r)
+	})
 }
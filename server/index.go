@@ -0,0 +1,154 @@
+package server
+
+import (
+	"carousel/renderer"
+	"carousel/templates"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// deckDir is a directory whose slides are served.
+type deckDir struct {
+	root        string
+	newRenderer RendererFactory
+}
+
+var indexTemplate = template.Must(template.New("index_tmpl").Parse(templates.Index_tmpl))
+
+// indexEntry is a slide listed on the index page.
+type indexEntry struct {
+	Name string // file path relative to the directory
+	Path string // URL path of the deck
+	Info *renderer.DeckInfo
+	Err  error
+}
+
+// deckPrefix returns the URL path prefix of the slide file, which is its
+// relative path without the extension.
+func deckPrefix(rel string) string {
+	return "/" + filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))) + "/"
+}
+
+// findSlides returns relative paths of slides in the directory, in either
+// format.
+func (dir *deckDir) findSlides() ([]string, error) {
+	var files []string
+
+	err := filepath.Walk(dir.root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// skip hidden directories such as .git
+		if fi.IsDir() && path != dir.root && strings.HasPrefix(fi.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		if !fi.IsDir() && renderer.IsDeckFile(path) {
+			rel, err := filepath.Rel(dir.root, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+
+		return nil
+	})
+
+	sort.Strings(files)
+	return files, err
+}
+
+// scanDirectory adds decks for new slides in the directory, and removes
+// decks whose slide is gone. It returns the slides served, which are all
+// but those having the prefix of another one in the other format.
+func (srv *Server) scanDirectory() []string {
+	dir := srv.deckDir()
+
+	files, err := dir.findSlides()
+	if err != nil {
+		srv.logger.Errorf("failed to scan '%s': %v", dir.root, err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.lastScan = time.Now()
+	found := make(map[string]bool)
+	var served []string
+
+	for _, rel := range files {
+		prefix := deckPrefix(rel)
+		if found[prefix] {
+			srv.logger.Warnf("'%s' is not served, as another slide is served on %s", rel, prefix)
+			continue
+		}
+		found[prefix] = true
+		served = append(served, rel)
+
+		if _, ok := srv.decks[prefix]; ok {
+			continue
+		}
+
+		filename := filepath.Join(dir.root, rel)
+		srv.decks[prefix] = newDeck(srv, prefix, filepath.Dir(filename), dir.newRenderer(filename))
+		srv.logger.Debugf("serving '%s' on %s", filename, prefix)
+	}
+
+	for prefix, d := range srv.decks {
+		if !found[prefix] {
+			d.close()
+			delete(srv.decks, prefix)
+		}
+	}
+
+	return served
+}
+
+func (srv *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	files := srv.scanDirectory()
+
+	entries := make([]indexEntry, len(files))
+	for i, rel := range files {
+		prefix := deckPrefix(rel)
+
+		srv.mu.Lock()
+		d, ok := srv.decks[prefix]
+		srv.mu.Unlock()
+
+		// the slide may be removed right after the scan
+		var info *renderer.DeckInfo
+		err := error(os.ErrNotExist)
+		if ok {
+			info, err = d.rend.Info()
+		}
+
+		entries[i] = indexEntry{
+			Name: filepath.ToSlash(rel),
+			Path: prefix,
+			Info: info,
+			Err:  err,
+		}
+	}
+
+	dir := srv.deckDir()
+	root, err := filepath.Abs(dir.root)
+	if err != nil {
+		root = dir.root
+	}
+
+	data := struct {
+		Dir   string
+		Decks []indexEntry
+	}{filepath.Base(root), entries}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.ExecuteTemplate(w, "index", data); err != nil {
+		srv.logger.Errorf("failed to render index: %v", err)
+	}
+}
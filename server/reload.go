@@ -0,0 +1,71 @@
+package server
+
+import (
+	"golang.org/x/net/websocket"
+	"sync"
+)
+
+// reloadHub keeps websocket connections of opened slides and notifies them
+// when the slides are re-rendered.
+type reloadHub struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]chan struct{}
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{
+		conns: make(map[*websocket.Conn]chan struct{}),
+	}
+}
+
+func (hub *reloadHub) handler(c *websocket.Conn) {
+	notify := make(chan struct{}, 1)
+
+	hub.mu.Lock()
+	hub.conns[c] = notify
+	hub.mu.Unlock()
+
+	defer func() {
+		hub.mu.Lock()
+		delete(hub.conns, c)
+		hub.mu.Unlock()
+
+		c.Close()
+	}()
+
+	// the client never sends anything; reading just detects closing
+	closed := make(chan struct{})
+	go func() {
+		b := make([]byte, 64)
+		for {
+			if _, err := c.Read(b); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-notify:
+			if err := websocket.Message.Send(c, "reload"); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func (hub *reloadHub) broadcast() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for _, notify := range hub.conns {
+		select {
+		case notify <- struct{}{}:
+		default:
+			// a notification is already pending
+		}
+	}
+}
@@ -1,11 +1,18 @@
 package server
 
 import (
+	"carousel/playground"
 	"carousel/renderer"
-	"code.google.com/p/go.tools/playground/socket"
+	"carousel/theme"
 	"fmt"
 	"github.com/scryner/logg"
+	"golang.org/x/net/websocket"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 type StaticContent struct {
@@ -15,23 +22,42 @@ type StaticContent struct {
 
 type FilePath string
 
+// RendererFactory creates a renderer for the slide file.
+type RendererFactory func(filename string) renderer.Renderer
+
 type Server struct {
 	gzipHttpServer
-	staticFiles map[string]StaticContent
-	workingPath string
-	rend        renderer.Renderer
+	staticFiles   map[string]StaticContent
+	watchInterval time.Duration
+	syncToken     string
+
+	mu          sync.Mutex
+	decks       map[string]*deck              // keyed by path prefix
+	dir         *deckDir                      // non-nil when serving a directory
+	lastScan    time.Time                     // of the directory, for unknown paths
+	staticCache map[string]*renderer.Rendered // keyed by path
+	startTime   time.Time                     // modification time of static files
+	conns       *connTracker
+	playground  *playground.Playground // nil if programs are not run locally
 
 	logger *logg.Logger
 }
 
-func NewServer(port int, enableGzip bool, workingPath string, rend renderer.Renderer, staticFiles map[string]StaticContent) *Server {
+// NewServer returns a server without slides; add them with ServeDeck or
+// ServeDirectory. Files of opened slides are polled every watchInterval to
+// reload browsers on change, and zero disables it.
+func NewServer(addr string, enableGzip bool, watchInterval time.Duration, staticFiles map[string]StaticContent) *Server {
 	logger := logg.GetDefaultLogger("server")
 
 	srv := &Server{
-		logger:      logger,
-		workingPath: workingPath,
-		rend:        rend,
-		staticFiles: staticFiles,
+		logger:        logger,
+		staticFiles:   staticFiles,
+		watchInterval: watchInterval,
+		syncToken:     newSyncToken(),
+		decks:         make(map[string]*deck),
+		staticCache:   make(map[string]*renderer.Rendered),
+		startTime:     time.Now(),
+		conns:         newConnTracker(),
 	}
 
 	serveHTTP := func(w http.ResponseWriter, r *http.Request) {
@@ -42,79 +68,341 @@ func NewServer(port int, enableGzip bool, workingPath string, rend renderer.Rend
 		}()
 
 		switch path {
-		case "/":
-			srv.handleSlides(w, r)
-
-		case "/refresh":
-			srv.handleRefresh(w, r)
-
 		case "/socket":
-            wsSrv := socket.NewHandler(r.URL)
-			wsSrv.Handler.ServeHTTP(w, r)
+			srv.servePlayground(w, r)
 
 		case "/compile":
-			srv.handleRedirectToGoPlaygroundAppEngine(w, r)
+			srv.serveCompile(w, r)
 
 		default:
-			srv.serveStaticFile(w, r, path)
+			if strings.HasPrefix(path, theme.URLPrefix) {
+				srv.serveThemeFile(w, r, path)
+				return
+			}
+
+			if _, ok := srv.staticFiles[path]; ok && strings.HasPrefix(path, "/static/") {
+				srv.serveStaticFile(w, r, path)
+				return
+			}
+
+			srv.serveDeck(w, r, path)
+		}
+	}
+
+	srv.addr = addr
+	srv.enableGzip = enableGzip
+	srv.serveHTTP = serveHTTP
+
+	return srv
+}
+
+// Start serves until Shutdown is called, and then returns nil.
+func (srv *Server) Start() error {
+	srv.logger.Infof("Starting server on %s", srv.addr)
+
+	go srv.prebuild()
+
+	return srv.start()
+}
+
+// prebuild builds snippets of every deck in the background if the
+// playground caches them, rendering decks not opened yet to find them.
+func (srv *Server) prebuild() {
+	pg := srv.getPlayground()
+	if pg == nil || pg.Cache() == nil {
+		return
+	}
+
+	srv.mu.Lock()
+	var decks []*deck
+	for _, d := range srv.decks {
+		decks = append(decks, d)
+	}
+	srv.mu.Unlock()
+
+	for _, d := range decks {
+		if err := d.rend.Refresh(); err != nil {
+			srv.logger.Errorf("failed to render '%s': %v", d.prefix, err)
+			continue
 		}
+		d.prebuild()
 	}
+}
+
+// Shutdown stops accepting connections, closes websockets, which also
+// kills programs running in the playground, and stops watching slides.
+// It waits for requests in flight and handlers of websockets until the
+// timeout.
+func (srv *Server) Shutdown(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
 
-	httpServer := gzipHttpServer{
-		port:       port,
-		enableGzip: enableGzip,
-		serveHTTP:  serveHTTP,
+	srv.conns.closeAll()
+	err := srv.stop(timeout)
+
+	srv.mu.Lock()
+	for _, d := range srv.decks {
+		d.close()
 	}
+	srv.mu.Unlock()
 
-	srv.gzipHttpServer = httpServer
+	if werr := srv.conns.wait(deadline); err == nil {
+		err = werr
+	}
 
-	return srv
+	return err
 }
 
-func (srv *Server) Start() {
-	srv.logger.Infof("Starting server on port %d", srv.port)
+// ServeDeck serves the slide at the root.
+func (srv *Server) ServeDeck(filename string, rend renderer.Renderer) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.decks["/"] = newDeck(srv, "/", filepath.Dir(filename), rend)
+}
+
+// ServeDirectory serves every slide found in dir under its own path, and an
+// index of them at the root.
+func (srv *Server) ServeDirectory(dir string, newRenderer RendererFactory) {
+	srv.mu.Lock()
+	srv.dir = &deckDir{root: dir, newRenderer: newRenderer}
+	srv.mu.Unlock()
+
+	srv.scanDirectory()
+}
+
+// ServePlayground runs programs of slides by pg through websockets.
+func (srv *Server) ServePlayground(pg *playground.Playground) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.playground = pg
+}
+
+func (srv *Server) getPlayground() *playground.Playground {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	return srv.playground
+}
+
+func (srv *Server) servePlayground(w http.ResponseWriter, r *http.Request) {
+	pg := srv.getPlayground()
+	if pg == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// closing the connection kills programs run through it
+	ws := websocket.Server{
+		Handshake: srv.checkOrigin,
+		Handler:   srv.trackWebsocket(pg.Handler()),
+	}
+	ws.ServeHTTP(w, r)
+}
+
+func (srv *Server) serveCompile(w http.ResponseWriter, r *http.Request) {
+	pg := srv.getPlayground()
+	if pg == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Header.Get("Origin") != "" {
+		if err := sameOrigin(r); err != nil {
+			srv.logger.Warnf("bad origin of /compile: %v", err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	pg.CompileHandler().ServeHTTP(w, r)
+}
+
+// checkOrigin accepts websockets of pages served by the server only, as
+// other pages must not run programs on the machine.
+func (srv *Server) checkOrigin(config *websocket.Config, r *http.Request) error {
+	if err := sameOrigin(r); err != nil {
+		srv.logger.Warnf("bad websocket origin: %v", err)
+		return websocket.ErrBadWebSocketOrigin
+	}
+
+	config.Origin, _ = url.Parse(r.Header.Get("Origin"))
+	return nil
+}
 
-	err := srv.start()
+// sameOrigin checks that the request comes from a page of the server.
+func sameOrigin(r *http.Request) error {
+	origin, err := url.Parse(r.Header.Get("Origin"))
 	if err != nil {
-		srv.logger.Fatalf("Failed to starting server: %v", err)
+		return err
 	}
+	if origin.Host != r.Host {
+		return fmt.Errorf("origin '%s' is not %s", r.Header.Get("Origin"), r.Host)
+	}
+
+	return nil
 }
 
-func (srv *Server) serveStaticFile(w http.ResponseWriter, r *http.Request, path string) {
-	if content, ok := srv.staticFiles[path]; ok {
-		switch t := content.Content.(type) {
-		case string:
-			w.Header().Set("Content-Type", content.Mine+"; charset=utf-8")
-			w.Write([]byte(t))
-		case []byte:
-			w.Header().Set("Content-Type", content.Mine)
-			w.Write(t)
-		case FilePath:
-			http.ServeFile(w, r, string(t))
+// SyncToken returns the token which a client must give to lead the
+// audience through /sync.
+func (srv *Server) SyncToken() string {
+	return srv.syncToken
+}
+
+func (srv *Server) serveDeck(w http.ResponseWriter, r *http.Request, path string) {
+	d, rest := srv.findDeck(path)
+	dir := srv.deckDir()
+
+	if d == nil && dir != nil {
+		if path == "/" {
+			srv.handleIndex(w, r)
+			return
+		}
+
+		// the slide may be added after the last scan
+		if srv.mayRescan() {
+			srv.scanDirectory()
+			d, rest = srv.findDeck(path)
+		}
+	}
+
+	if d == nil {
+		if dir != nil {
+			http.ServeFile(w, r, filepath.Join(dir.root, filepath.FromSlash(path)))
+		} else {
+			http.NotFound(w, r)
+		}
+		return
+	}
+
+	// relative paths in slides need the trailing slash
+	if rest == "" {
+		http.Redirect(w, r, d.prefix, http.StatusMovedPermanently)
+		return
+	}
+
+	d.serveHTTP(w, r, rest)
+}
+
+func (srv *Server) deckDir() *deckDir {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	return srv.dir
+}
+
+// minRescanInterval bounds rescans of the directory for unknown paths when
+// files are not watched.
+const minRescanInterval = time.Second
+
+// mayRescan tells whether the directory may be scanned again for an
+// unknown path. Scans are limited to one in the watch interval, so that
+// requests of missing files can't keep walking the directory.
+func (srv *Server) mayRescan() bool {
+	interval := srv.watchInterval
+	if interval < minRescanInterval {
+		interval = minRescanInterval
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
 
+	now := time.Now()
+	if now.Sub(srv.lastScan) < interval {
+		return false
+	}
+	srv.lastScan = now
+
+	return true
+}
+
+// findDeck returns the deck having the longest prefix of path, and the
+// rest of path. rest is empty if path lacks the trailing slash of prefix.
+func (srv *Server) findDeck(path string) (found *deck, rest string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for prefix, d := range srv.decks {
+		var r string
+
+		switch {
+		case strings.HasPrefix(path, prefix):
+			r = path[len(prefix)-1:]
+		case path+"/" == prefix:
+			r = ""
 		default:
-			srv.logger.Errorf("unknown static content: %v", t)
-			http.Error(w, "unknown static content", http.StatusInternalServerError)
+			continue
+		}
+
+		if found == nil || len(prefix) > len(found.prefix) {
+			found, rest = d, r
 		}
-	} else {
-		fpath := fmt.Sprintf("%s%s", srv.workingPath, r.URL.Path)
-		http.ServeFile(w, r, fpath)
 	}
+
+	return
 }
 
-func (srv *Server) handleSlides(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err := srv.rend.Render(w)
+func (srv *Server) serveStaticFile(w http.ResponseWriter, r *http.Request, path string) {
+	content, ok := srv.staticFiles[path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch t := content.Content.(type) {
+	case string:
+		srv.serveStaticBytes(w, r, path, content.Mine+"; charset=utf-8", []byte(t))
+	case []byte:
+		srv.serveStaticBytes(w, r, path, content.Mine, t)
+	case FilePath:
+		http.ServeFile(w, r, string(t))
+
+	default:
+		srv.logger.Errorf("unknown static content: %v", t)
+		http.Error(w, "unknown static content", http.StatusInternalServerError)
+	}
+}
+
+func (srv *Server) serveStaticBytes(w http.ResponseWriter, r *http.Request, path, contentType string, body []byte) {
+	rendered, err := srv.cachedStatic(path, body)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("error while rendering: %v", err), http.StatusInternalServerError)
+		srv.logger.Errorf("failed to prepare '%s': %v", path, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+
+	srv.serveRendered(w, r, contentType, rendered)
 }
 
-func (srv *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
-	err := srv.rend.Refresh()
+// serveThemeFile serves files of themes under /static/themes/<name>/.
+func (srv *Server) serveThemeFile(w http.ResponseWriter, r *http.Request, path string) {
+	rest := strings.TrimPrefix(path, theme.URLPrefix)
+
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	t, err := theme.Lookup(rest[:i])
 	if err != nil {
-		http.Error(w, fmt.Sprintf("error while refreshing: %v", err), http.StatusInternalServerError)
+		http.NotFound(w, r)
+		return
+	}
+
+	name := rest[i+1:]
+
+	// built-in themes have only the style sheet
+	if t.IsBuiltin() && name == theme.CSSFile && t.CSS != "" {
+		srv.serveStaticBytes(w, r, path, "text/css; charset=utf-8", []byte(t.CSS))
+		return
+	}
+
+	file, ok := t.File(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusFound)
+	http.ServeFile(w, r, file)
 }
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"golang.org/x/net/websocket"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrDrainTimeout is returned by Shutdown when connections are still open
+// after the timeout.
+var ErrDrainTimeout = errors.New("connections are still open after the drain timeout")
+
+// connTracker keeps websocket connections, which the HTTP server forgets
+// once they are hijacked, to close them at shutdown.
+type connTracker struct {
+	mu      sync.Mutex
+	conns   map[io.Closer]struct{}
+	closing bool
+	wg      sync.WaitGroup
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		conns: make(map[io.Closer]struct{}),
+	}
+}
+
+// track adds the connection, or returns false if shutting down already.
+func (t *connTracker) track(c io.Closer) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closing {
+		return false
+	}
+
+	t.conns[c] = struct{}{}
+	t.wg.Add(1)
+
+	return true
+}
+
+// untrack removes the connection once its handler returns.
+func (t *connTracker) untrack(c io.Closer) {
+	t.mu.Lock()
+	delete(t.conns, c)
+	t.mu.Unlock()
+
+	t.wg.Done()
+}
+
+// closeAll closes every connection and refuses new ones. Handlers notice
+// it by failing to read.
+func (t *connTracker) closeAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closing = true
+	for c := range t.conns {
+		c.Close()
+	}
+}
+
+// wait waits for handlers of the connections to return until the deadline.
+// Handlers returned already are never taken as timed out, even if the
+// deadline has passed.
+func (t *connTracker) wait(deadline time.Time) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(deadline.Sub(time.Now()))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+	}
+
+	if t.open() > 0 {
+		return ErrDrainTimeout
+	}
+
+	return nil
+}
+
+// open returns the number of connections whose handlers haven't returned.
+func (t *connTracker) open() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.conns)
+}
+
+// trackWebsocket returns the handler h whose connections are closed at
+// shutdown.
+func (srv *Server) trackWebsocket(h websocket.Handler) websocket.Handler {
+	return func(c *websocket.Conn) {
+		if !srv.conns.track(c) {
+			c.Close()
+			return
+		}
+		defer srv.conns.untrack(c)
+
+		h(c)
+	}
+}
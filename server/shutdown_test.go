@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func TestConnTrackerWait(t *testing.T) {
+	tr := newConnTracker()
+
+	// drained at once, even past the deadline
+	if err := tr.wait(time.Now().Add(-time.Second)); err != nil {
+		t.Errorf("no connection, past the deadline: %v", err)
+	}
+
+	c := nopCloser{}
+	if !tr.track(c) {
+		t.Fatal("connection is refused before shutting down")
+	}
+	tr.closeAll()
+
+	if tr.track(nopCloser{}) {
+		t.Error("connection is tracked while shutting down")
+	}
+
+	if err := tr.wait(time.Now().Add(10 * time.Millisecond)); err != ErrDrainTimeout {
+		t.Errorf("open connection: got %v; want %v", err, ErrDrainTimeout)
+	}
+
+	tr.untrack(c)
+
+	if err := tr.wait(time.Now()); err != nil {
+		t.Errorf("connection closed, at the deadline: %v", err)
+	}
+}
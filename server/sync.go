@@ -0,0 +1,118 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"golang.org/x/net/websocket"
+	"sync"
+)
+
+// syncMessage is the wire format of the /sync websocket.
+type syncMessage struct {
+	Slide int // 1-indexed slide number
+}
+
+// syncHub relays the current slide from the presenter to the audience.
+// Clients connected with the presenter token publish their position, and
+// every other client follows it. Clients joining late are sent the
+// current position at once.
+type syncHub struct {
+	token string
+
+	mu      sync.Mutex
+	clients map[*websocket.Conn]chan syncMessage
+	current int
+}
+
+func newSyncHub(token string) *syncHub {
+	return &syncHub{
+		token:   token,
+		clients: make(map[*websocket.Conn]chan syncMessage),
+	}
+}
+
+func newSyncToken() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func (hub *syncHub) isPresenter(c *websocket.Conn) bool {
+	token := c.Request().URL.Query().Get("token")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(hub.token)) == 1
+}
+
+func (hub *syncHub) handler(c *websocket.Conn) {
+	out := make(chan syncMessage, 8)
+	presenter := hub.isPresenter(c)
+
+	hub.mu.Lock()
+	hub.clients[c] = out
+	if hub.current > 0 {
+		out <- syncMessage{Slide: hub.current}
+	}
+	hub.mu.Unlock()
+
+	defer func() {
+		hub.mu.Lock()
+		delete(hub.clients, c)
+		hub.mu.Unlock()
+
+		c.Close()
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+
+		for {
+			var m syncMessage
+			if err := websocket.JSON.Receive(c, &m); err != nil {
+				return
+			}
+
+			// only the presenter leads
+			if presenter {
+				hub.publish(c, m)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case m := <-out:
+			if err := websocket.JSON.Send(c, m); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// publish sends the slide position to every client except the sender.
+func (hub *syncHub) publish(from *websocket.Conn, m syncMessage) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if m.Slide < 1 || m.Slide == hub.current {
+		return
+	}
+	hub.current = m.Slide
+
+	for c, out := range hub.clients {
+		if c == from {
+			continue
+		}
+
+		select {
+		case out <- m:
+		default:
+			// the client is too slow; it will catch up with the next one
+		}
+	}
+}
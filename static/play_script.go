@@ -4228,8 +4228,6 @@ here's a skeleton implementation of a playground transport.
 function HTTPTransport() {
     'use strict';
 
-    // TODO(adg): support stderr
-
     function playback(output, events) {
         var timeout;
         output({
@@ -4245,7 +4243,7 @@ function HTTPTransport() {
             var e = events.shift();
             if (e.Delay === 0) {
                 output({
-                    Kind: 'stdout',
+                    Kind: e.Kind || 'stdout',
                     Body: e.Message
                 });
                 next();
@@ -4253,7 +4251,7 @@ function HTTPTransport() {
             }
             timeout = setTimeout(function() {
                 output({
-                    Kind: 'stdout',
+                    Kind: e.Kind || 'stdout',
                     Body: e.Message
                 });
                 next();
@@ -4290,7 +4288,8 @@ function HTTPTransport() {
                 type: 'POST',
                 data: {
                     'version': 2,
-                    'body': body
+                    'body': body,
+                    'ext': options && options.Ext || ''
                 },
                 dataType: 'json',
                 success: function(data) {
@@ -4717,7 +4716,8 @@ function initPlayground(transport) {
             outpre.innerHTML = "";
             run1.style.display = "none";
             var options = {
-                Race: e.shiftKey
+                Race: e.shiftKey,
+                Ext: code.getAttribute('data-ext') || ''
             };
             running = transport.Run(text(code), PlaygroundOutput(outpre), options);
         }
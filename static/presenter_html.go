@@ -0,0 +1,285 @@
+package static
+
+const Presenter_html = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Presenter console</title>
+    <meta charset='utf-8'>
+    <style>
+      html, body {
+        height: 100%;
+        margin: 0;
+        background: rgb(40, 40, 40);
+        color: rgb(230, 230, 230);
+        font-family: 'Open Sans', Arial, sans-serif;
+      }
+
+      #toolbar {
+        height: 48px;
+        line-height: 48px;
+        padding: 0 16px;
+        font-size: 24px;
+        background: rgb(20, 20, 20);
+      }
+      #toolbar span.label {
+        font-size: 14px;
+        color: rgb(150, 150, 150);
+        margin: 0 8px 0 24px;
+      }
+      #toolbar input {
+        width: 48px;
+      }
+      #remaining.overtime {
+        color: rgb(230, 80, 80);
+      }
+
+      .frame {
+        position: absolute;
+        overflow: hidden;
+        background: white;
+      }
+      .frame iframe {
+        width: 1100px;
+        height: 750px;
+        border: 0;
+        transform-origin: 0 0;
+        -webkit-transform-origin: 0 0;
+      }
+
+      #current-frame {
+        top: 64px;
+        left: 16px;
+      }
+      #next-frame {
+        top: 64px;
+      }
+      #notes {
+        position: absolute;
+        right: 16px;
+        bottom: 16px;
+        overflow-y: auto;
+        font-size: 24px;
+        line-height: 1.4em;
+      }
+    </style>
+  </head>
+
+  <body>
+    <div id="toolbar">
+      <span id="slide-no">1</span>
+      <span class="label">elapsed</span><span id="elapsed">0:00</span>
+      <span class="label">remaining</span><span id="remaining">-</span>
+      <span class="label">duration (min)</span><input id="duration" type="number" min="0">
+      <button id="reset">Reset timer</button>
+    </div>
+
+    <div id="current-frame" class="frame"><iframe id="current" src="./"></iframe></div>
+    <div id="next-frame" class="frame"><iframe id="next" src="./"></iframe></div>
+    <div id="notes"></div>
+
+    <script>
+    (function() {
+      'use strict';
+
+      var RETRY_DELAY = 1000;
+      var SLIDE_WIDTH = 1100, SLIDE_HEIGHT = 750;
+
+      var current = document.getElementById('current');
+      var next = document.getElementById('next');
+      var notes = document.getElementById('notes');
+
+      var slideNo = parseInt(location.hash.substr(1)) || 1;
+      var websocket;
+
+      /* Layout */
+
+      function place(frame, left, top, width) {
+        var scale = width / SLIDE_WIDTH;
+        var div = frame.parentNode;
+
+        div.style.left = left + 'px';
+        div.style.top = top + 'px';
+        div.style.width = width + 'px';
+        div.style.height = (SLIDE_HEIGHT * scale) + 'px';
+
+        frame.style.transform = 'scale(' + scale + ')';
+        frame.style.webkitTransform = 'scale(' + scale + ')';
+      }
+
+      function layout() {
+        var w = window.innerWidth, h = window.innerHeight;
+        var currentWidth = Math.min((w - 48) * 0.6, (h - 80) * SLIDE_WIDTH / SLIDE_HEIGHT);
+        var nextLeft = currentWidth + 32;
+        var nextWidth = w - nextLeft - 16;
+
+        place(current, 16, 64, currentWidth);
+        place(next, nextLeft, 64, nextWidth);
+
+        notes.style.left = nextLeft + 'px';
+        notes.style.top = (64 + SLIDE_HEIGHT * nextWidth / SLIDE_WIDTH + 16) + 'px';
+      }
+
+      /* Slides */
+
+      function slideCount() {
+        var win = current.contentWindow;
+        return win.slideEls ? win.slideEls.length : 0;
+      }
+
+      function moveFrame(frame, no) {
+        var win = frame.contentWindow;
+        if (!win.slideEls) {
+          return;
+        }
+
+        no = Math.max(1, Math.min(no, win.slideEls.length));
+        if (win.curSlide != no - 1) {
+          win.curSlide = no - 1;
+          win.updateSlides();
+        }
+      }
+
+      function showNotes() {
+        var win = current.contentWindow;
+        if (!win.slideEls) {
+          return;
+        }
+
+        var aside = win.slideEls[slideNo - 1].querySelector('aside.notes');
+        notes.innerHTML = aside ? aside.innerHTML : '';
+      }
+
+      function show(no, publish) {
+        var count = slideCount();
+        if (count == 0) {
+          return;
+        }
+
+        slideNo = Math.max(1, Math.min(no, count));
+
+        moveFrame(current, slideNo);
+        moveFrame(next, slideNo + 1);
+        showNotes();
+
+        document.getElementById('slide-no').textContent = slideNo + ' / ' + count;
+        location.replace('#' + slideNo);
+
+        if (publish && websocket && websocket.readyState == WebSocket.OPEN) {
+          websocket.send(JSON.stringify({Slide: slideNo}));
+        }
+      }
+
+      current.addEventListener('load', function() {
+        var win = current.contentWindow;
+
+        // follow moves made inside the frame itself (clicks, keys)
+        win.document.addEventListener('slideenter', function(e) {
+          if (e.slideNumber != slideNo) {
+            show(e.slideNumber, true);
+          }
+        }, false);
+
+        show(slideNo, false);
+      }, false);
+
+      next.addEventListener('load', function() {
+        moveFrame(next, slideNo + 1);
+      }, false);
+
+      document.addEventListener('keydown', function(e) {
+        switch (e.keyCode) {
+          case 39: // right arrow
+          case 40: // down arrow
+          case 34: // PgDn
+          case 32: // space
+          case 13: // Enter
+            if (e.target.tagName == 'INPUT') {
+              return;
+            }
+            show(slideNo + 1, true);
+            e.preventDefault();
+            break;
+          case 37: // left arrow
+          case 38: // up arrow
+          case 33: // PgUp
+            if (e.target.tagName == 'INPUT') {
+              return;
+            }
+            show(slideNo - 1, true);
+            e.preventDefault();
+            break;
+        }
+      }, false);
+
+      /* Sync */
+
+      function connect() {
+        var base = window.location.pathname.replace(/[^\/]*$/, '');
+        websocket = new WebSocket('ws://' + window.location.host + base + 'sync' + location.search);
+
+        websocket.onopen = function() {
+          show(slideNo, true);
+        };
+
+        websocket.onmessage = function(e) {
+          var m = JSON.parse(e.data);
+          show(m.Slide, false);
+        };
+
+        websocket.onclose = function() {
+          websocket = null;
+          window.setTimeout(connect, RETRY_DELAY);
+        };
+      }
+
+      /* Timer */
+
+      var startedAt = new Date().getTime();
+      var duration = document.getElementById('duration');
+
+      function formatTime(sec) {
+        var sign = sec < 0 ? '-' : '';
+        sec = Math.abs(sec);
+
+        var min = Math.floor(sec / 60);
+        var s = sec % 60;
+        return sign + min + ':' + (s < 10 ? '0' : '') + s;
+      }
+
+      function tick() {
+        var elapsed = Math.floor((new Date().getTime() - startedAt) / 1000);
+        document.getElementById('elapsed').textContent = formatTime(elapsed);
+
+        var remaining = document.getElementById('remaining');
+        var min = parseInt(duration.value);
+        if (min > 0) {
+          var left = min * 60 - elapsed;
+          remaining.textContent = formatTime(left);
+          remaining.className = left < 0 ? 'overtime' : '';
+        } else {
+          remaining.textContent = '-';
+          remaining.className = '';
+        }
+      }
+
+      document.getElementById('reset').addEventListener('click', function() {
+        startedAt = new Date().getTime();
+        tick();
+      }, false);
+
+      var m = /[?&]duration=([0-9]+)/.exec(location.search);
+      if (m) {
+        duration.value = m[1];
+      }
+
+      window.addEventListener('resize', layout, false);
+      window.setInterval(tick, 1000);
+
+      layout();
+      tick();
+      connect();
+    })();
+    </script>
+  </body>
+</html>
+`
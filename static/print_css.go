@@ -10,6 +10,10 @@ body {
   display: block !important;
 }
 
+.render-error {
+  display: none;
+}
+
 .slides {
   left: 0;
   top: 0;
@@ -37,8 +41,8 @@ div.code {
   background: rgb(240, 240, 240);
 }
 
-/* hide click areas */
-.slide-area, #prev-slide-area, #next-slide-area {
+/* hide click areas and follow mode status */
+.slide-area, #prev-slide-area, #next-slide-area, #sync-status {
   display: none;
 }
 
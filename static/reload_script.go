@@ -0,0 +1,38 @@
+package static
+
+const Reload_js = `
+// Reloads the slides when the server notifies that the input file or one of
+// its assets is changed. The current slide is kept by the '#N' hash.
+
+(function() {
+  'use strict';
+
+  var RETRY_DELAY = 1000;
+
+  function connect(reloadOnOpen) {
+    var base = window.location.pathname.replace(/[^\/]*$/, '');
+    var websocket = new WebSocket('ws://' + window.location.host + base + 'reload');
+
+    websocket.onopen = function() {
+      // the server was restarted while we were waiting
+      if (reloadOnOpen) {
+        window.location.reload();
+      }
+    };
+
+    websocket.onmessage = function(e) {
+      if (e.data == 'reload') {
+        window.location.reload();
+      }
+    };
+
+    websocket.onclose = function() {
+      window.setTimeout(function() {
+        connect(true);
+      }, RETRY_DELAY);
+    };
+  }
+
+  connect(false);
+})();
+`
@@ -441,11 +441,23 @@ function addFontStyle() {
 };
 
 function addGeneralStyle() {
-  var el = document.createElement('link');
-  el.rel = 'stylesheet';
-  el.type = 'text/css';
-  el.href = PERMANENT_URL_PREFIX + 'styles.css';
-  document.body.appendChild(el);
+  // exported slides have style sheets inlined already
+  if (!window['INLINE_STYLES']) {
+    var el = document.createElement('link');
+    el.rel = 'stylesheet';
+    el.type = 'text/css';
+    el.href = PERMANENT_URL_PREFIX + 'styles.css';
+    document.body.appendChild(el);
+
+    // the theme overrides the default style
+    if (window['THEME_CSS']) {
+      var el = document.createElement('link');
+      el.rel = 'stylesheet';
+      el.type = 'text/css';
+      el.href = window['THEME_CSS'];
+      document.body.appendChild(el);
+    }
+  }
 
   var el = document.createElement('meta');
   el.name = 'viewport';
@@ -459,6 +471,10 @@ function addGeneralStyle() {
 };
 
 function addPrintStyle() {
+  if (window['INLINE_STYLES']) {
+    return;
+  }
+
   var el = document.createElement('link');
   el.rel = 'stylesheet';
   el.type = 'text/css';
@@ -367,6 +367,46 @@ p.link {
 div.code {
   outline: 0px solid transparent;
 }
+
+/* Error of refreshing shown over the last good version */
+.render-error {
+  position: fixed;
+  left: 0;
+  right: 0;
+  top: 0;
+  z-index: 1000;
+
+  padding: 8px 16px;
+
+  background: rgb(200, 40, 40);
+  color: white;
+  font-family: 'Droid Sans Mono', 'Courier New', monospace;
+  font-size: 14px;
+  white-space: pre-wrap;
+}
+
+/* Syntax highlighting */
+.tok-keyword {
+  color: rgb(0, 0, 136);
+}
+.tok-string {
+  color: rgb(0, 128, 0);
+}
+.tok-number {
+  color: rgb(0, 102, 102);
+}
+.tok-comment {
+  color: rgb(136, 136, 136);
+}
+.tok-builtin {
+  color: rgb(102, 0, 102);
+}
+.tok-variable {
+  color: rgb(136, 68, 0);
+}
+.tok-key {
+  color: rgb(0, 68, 136);
+}
 div.playground {
   position: relative;
 }
@@ -429,6 +469,30 @@ div.output .buttons {
 	line-height: 1.2em;
 }
 
+/* Speaker notes are shown only in the presenter console */
+aside.notes {
+  display: none;
+}
+
+/* Follow mode status */
+#sync-status {
+  position: fixed;
+  right: 10px;
+  bottom: 10px;
+  padding: 4px 10px;
+  border-radius: 4px;
+  font-size: 14px;
+  color: white;
+  cursor: pointer;
+  z-index: 100;
+}
+#sync-status.following {
+  background: rgba(0, 128, 0, 0.6);
+}
+#sync-status.detached {
+  background: rgba(192, 0, 0, 0.8);
+}
+
 /* Output resize details */
 .ui-resizable-handle {
   position: absolute;
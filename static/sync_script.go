@@ -0,0 +1,132 @@
+package static
+
+const Sync_js = `
+// Follow mode. The slides opened with the presenter token ('?token=...')
+// lead, and every other window follows them through the server. A follower
+// detaches by moving on its own, and follows again by pressing 'F'.
+// Slides embedded in the presenter console (iframes) are driven by the
+// console itself, so they do not connect.
+
+(function() {
+  'use strict';
+
+  var RETRY_DELAY = 1000;
+
+  if (window.self !== window.top) {
+    return;
+  }
+
+  var token = (/[?&]token=([^&#]*)/.exec(location.search) || [])[1];
+  var presenter = !!token;
+
+  var websocket;
+  var following = true;
+  var remoteSlide = 0; // last slide the presenter is on
+  var moving = false;  // moving by a message, not by the user
+  var statusEl;
+
+  function goToSlide(no) {
+    if (no < 1 || no > slideEls.length || no - 1 == curSlide) {
+      return;
+    }
+
+    moving = true;
+    curSlide = no - 1;
+    updateSlides();
+    moving = false;
+  }
+
+  function showStatus() {
+    if (presenter) {
+      return;
+    }
+
+    if (!statusEl) {
+      statusEl = document.createElement('div');
+      statusEl.id = 'sync-status';
+      statusEl.addEventListener('click', follow, false);
+      document.body.appendChild(statusEl);
+    }
+
+    if (following) {
+      statusEl.className = 'following';
+      statusEl.textContent = 'Following the presenter';
+    } else {
+      statusEl.className = 'detached';
+      statusEl.textContent = 'Detached - press F to follow the presenter';
+    }
+  }
+
+  function follow() {
+    following = true;
+    showStatus();
+    goToSlide(remoteSlide);
+  }
+
+  function detach() {
+    following = false;
+    showStatus();
+  }
+
+  function connect() {
+    var base = window.location.pathname.replace(/[^\/]*$/, '');
+    var url = 'ws://' + window.location.host + base + 'sync';
+    if (presenter) {
+      url += '?token=' + token;
+    }
+
+    websocket = new WebSocket(url);
+
+    websocket.onopen = function() {
+      if (presenter) {
+        websocket.send(JSON.stringify({Slide: curSlide + 1}));
+      } else {
+        showStatus();
+      }
+    };
+
+    websocket.onmessage = function(e) {
+      var m = JSON.parse(e.data);
+      remoteSlide = m.Slide;
+
+      if (following) {
+        goToSlide(remoteSlide);
+      }
+    };
+
+    websocket.onclose = function() {
+      websocket = null;
+      window.setTimeout(connect, RETRY_DELAY);
+    };
+  }
+
+  document.addEventListener('slideenter', function(e) {
+    if (moving || !websocket || websocket.readyState != WebSocket.OPEN) {
+      return;
+    }
+
+    if (presenter) {
+      websocket.send(JSON.stringify({Slide: e.slideNumber}));
+    } else if (following && e.slideNumber != remoteSlide) {
+      detach();
+    }
+  }, false);
+
+  document.addEventListener('keydown', function(e) {
+    if (presenter || e.target.classList.contains('code')) {
+      return;
+    }
+
+    if (e.keyCode == 70) { // F
+      if (following) {
+        detach();
+      } else {
+        follow();
+      }
+      e.preventDefault();
+    }
+  }, false);
+
+  connect();
+})();
+`
@@ -0,0 +1,135 @@
+package static
+
+// Style sheets of built-in themes. They are loaded after Styles_css, so they
+// only override colors.
+
+const Dark_css = `
+body {
+  background: rgb(30, 30, 30);
+  background: -o-radial-gradient(rgb(60, 60, 60), rgb(20, 20, 20));
+  background: -moz-radial-gradient(rgb(60, 60, 60), rgb(20, 20, 20));
+  background: -webkit-radial-gradient(rgb(60, 60, 60), rgb(20, 20, 20));
+  background: -webkit-gradient(radial, 50% 50%, 0, 50% 50%, 500, from(rgb(60, 60, 60)), to(rgb(20, 20, 20)));
+}
+
+.slides > article {
+  background-color: rgb(40, 42, 46);
+  border: 1px solid rgba(255, 255, 255, .15);
+
+  color: rgb(220, 220, 220);
+  text-shadow: none;
+}
+
+h1, h2, h3 {
+  color: rgb(240, 240, 240);
+}
+
+a {
+  color: rgb(102, 178, 255);
+}
+a:visited {
+  color: rgba(102, 178, 255, .75);
+}
+a:hover {
+  color: white;
+}
+
+div.code {
+  background: rgb(28, 29, 32);
+  border: 1px solid rgb(70, 70, 70);
+}
+pre, code {
+  color: rgb(220, 220, 220);
+}
+
+td,
+th {
+  border: 1px solid rgb(80, 80, 80);
+}
+
+.tok-keyword {
+  color: rgb(198, 120, 221);
+}
+.tok-string {
+  color: rgb(152, 195, 121);
+}
+.tok-number {
+  color: rgb(209, 154, 102);
+}
+.tok-comment {
+  color: rgb(120, 126, 138);
+}
+.tok-builtin {
+  color: rgb(86, 182, 194);
+}
+.tok-variable {
+  color: rgb(224, 108, 117);
+}
+.tok-key {
+  color: rgb(97, 175, 239);
+}
+`
+
+const HighContrast_css = `
+body {
+  background: black;
+}
+
+.slides > article {
+  background-color: black;
+  border: 2px solid white;
+
+  color: white;
+  text-shadow: none;
+  letter-spacing: 0;
+}
+
+h1, h2, h3 {
+  color: yellow;
+  letter-spacing: 0;
+}
+
+b {
+  font-weight: 700;
+}
+
+a, a:visited {
+  color: cyan;
+  text-decoration: underline;
+}
+a:hover {
+  color: yellow;
+}
+
+div.code {
+  background: black;
+  border: 2px solid white;
+}
+pre, code {
+  color: white;
+  letter-spacing: 0;
+}
+
+td,
+th {
+  border: 2px solid white;
+}
+
+.tok-keyword {
+  color: cyan;
+  font-weight: 700;
+}
+.tok-string {
+  color: lime;
+}
+.tok-number, .tok-variable {
+  color: yellow;
+}
+.tok-comment {
+  color: silver;
+  font-style: italic;
+}
+.tok-builtin, .tok-key {
+  color: magenta;
+}
+`
@@ -31,12 +31,16 @@ It determines how the formatting actions are rendered.
 {{end}}
 
 {{define "code"}}
-  <div class="code{{if playable .}} playground{{end}}" contenteditable="true" spellcheck="false">{{.Text}}</div>
+  <div class="code{{if playable .}} playground{{end}}"{{if playable .}} data-ext="{{.Ext}}"{{end}} contenteditable="true" spellcheck="false">{{.Text}}</div>
+{{end}}
+
+{{define "highlighted"}}
+  <div class="code" contenteditable="true" spellcheck="false"><pre>{{.HTML}}</pre></div>
 {{end}}
 
 {{define "image"}}
 <div class="image">
-  <img src="{{.URL}}"{{with .Height}} height="{{.}}"{{end}}{{with .Width}} width="{{.}}"{{end}}>
+  <img src="{{assetURL .URL}}" alt="{{alt .URL}}"{{with .Height}} height="{{.}}"{{end}}{{with .Width}} width="{{.}}"{{end}}>
 </div>
 {{end}}
 
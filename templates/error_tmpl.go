@@ -0,0 +1,86 @@
+package templates
+
+const Error_tmpl = `
+{/* This is the error template. It tells why the slides can't be rendered. */}
+
+{{define "error"}}
+<!DOCTYPE html>
+<html>
+  <head>
+    <title>Error: {{.File}}</title>
+    <meta charset='utf-8'>
+    <style>
+      body {
+        margin: 40px auto;
+        max-width: 1000px;
+        font-family: 'Open Sans', Arial, sans-serif;
+        color: rgb(60, 60, 60);
+      }
+      h1 {
+        color: rgb(192, 0, 0);
+      }
+      .message {
+        font-size: 20px;
+      }
+      .directive {
+        font-family: 'Droid Sans Mono', 'Courier New', monospace;
+        background: rgb(240, 240, 240);
+        padding: 0 4px;
+      }
+      table.source {
+        margin-top: 24px;
+        border-collapse: collapse;
+        font-family: 'Droid Sans Mono', 'Courier New', monospace;
+        font-size: 15px;
+        width: 100%;
+      }
+      table.source td {
+        padding: 2px 10px;
+        white-space: pre;
+      }
+      table.source td.number {
+        text-align: right;
+        color: rgb(150, 150, 150);
+        width: 1%;
+      }
+      table.source tr.failed {
+        background: rgb(255, 230, 230);
+      }
+      table.source tr.failed td.number {
+        color: rgb(192, 0, 0);
+      }
+      .hint {
+        margin-top: 24px;
+        font-size: 14px;
+        color: rgb(120, 120, 120);
+      }
+    </style>
+  </head>
+
+  <body>
+    <h1>Can't render the slides</h1>
+
+    <p class="message">
+      {{.File}}{{if .Line}}, line {{.Line}}{{end}}:
+      {{with .Directive}}<span class="directive">{{.}}</span> failed:{{end}}
+      {{.Message}}
+    </p>
+
+    {{with .Context}}
+    <table class="source">
+    {{range .}}
+      <tr{{if .Failed}} class="failed"{{end}}><td class="number">{{.Number}}</td><td>{{.Text}}</td></tr>
+    {{end}}
+    </table>
+    {{end}}
+
+    <p class="hint">
+      {{if .LiveReload}}This page reloads itself when the slides are fixed.{{else}}Reload this page when the slides are fixed.{{end}}
+    </p>
+  </body>
+  {{if .LiveReload}}
+  <script src='/static/reload.js'></script>
+  {{end}}
+</html>
+{{end}}
+`
@@ -0,0 +1,71 @@
+package templates
+
+const Index_tmpl = `
+{/* This is the index template. It lists the slides of a directory. */}
+
+{{define "index"}}
+<!DOCTYPE html>
+<html>
+  <head>
+    <title>{{.Dir}}</title>
+    <meta charset='utf-8'>
+    <style>
+      body {
+        margin: 40px auto;
+        max-width: 900px;
+        font-family: 'Open Sans', Arial, sans-serif;
+        color: rgb(60, 60, 60);
+      }
+      li {
+        list-style: none;
+        margin-bottom: 24px;
+      }
+      a.title {
+        font-size: 24px;
+        font-weight: 600;
+        color: rgb(0, 102, 204);
+        text-decoration: none;
+      }
+      .detail {
+        font-size: 14px;
+        color: rgb(120, 120, 120);
+      }
+      .tag {
+        display: inline-block;
+        padding: 0 6px;
+        margin-right: 4px;
+        border-radius: 3px;
+        background: rgb(230, 230, 230);
+      }
+      .error {
+        color: rgb(192, 0, 0);
+      }
+    </style>
+  </head>
+
+  <body>
+    <h1>{{.Dir}}</h1>
+
+    <ul>
+    {{range .Decks}}
+      <li>
+        <a class="title" href="{{.Path}}">{{if .Info}}{{.Info.Title}}{{else}}{{.Name}}{{end}}</a>
+        <div class="detail">{{.Name}}</div>
+        {{with .Info}}
+          {{with .Subtitle}}<div>{{.}}</div>{{end}}
+          <div class="detail">
+            {{if not .Time.IsZero}}{{.Time.Format "2 January 2006"}}{{end}}
+            {{range $i, $a := .Authors}}{{if $i}}, {{end}}{{$a}}{{end}}
+          </div>
+          {{with .Tags}}<div class="detail">{{range .}}<span class="tag">{{.}}</span>{{end}}</div>{{end}}
+        {{end}}
+        {{with .Err}}<div class="detail error">{{.}}</div>{{end}}
+      </li>
+    {{else}}
+      <li>No slides found.</li>
+    {{end}}
+    </ul>
+  </body>
+</html>
+{{end}}
+`
@@ -9,12 +9,25 @@ const Slides_tmpl = `
   <head>
     <title>{{.Title}}</title>
     <meta charset='utf-8'>
+    {{with .Inline}}
+    <script>var INLINE_STYLES = true;</script>
+    <script>{{.SlidesJS}}</script>
+    <style>{{.StyleCSS}}</style>
+    {{with .ThemeCSS}}<style>{{.}}</style>{{end}}
+    <style media='print'>{{.PrintCSS}}</style>
+    {{else}}
+    {{with $.Theme.CSSURL}}<script>var THEME_CSS = {{.}};</script>{{end}}
     <script src='/static/slides.js'></script>
+    {{end}}
   </head>
 
   <body style='display: none'>
 
-    <section class='slides layout-widescreen'>
+    {{with .Failure}}
+    <div class='render-error'>Showing the last good version; {{.}}</div>
+    {{end}}
+
+    <section class='slides {{.Theme.Layout}}'>
       
       <article>
         <h1>{{.Title}}</h1>
@@ -36,6 +49,11 @@ const Slides_tmpl = `
       {{else}}
         <h2>{{$s.Title}}</h2>
       {{end}}
+      {{with index $.Notes $i}}
+        <aside class="notes">
+        {{range .}}<p>{{style .}}</p>{{end}}
+        </aside>
+      {{end}}
       </article>
   <!-- end of slide {{$i}} -->
   {{end}}{{/* of Slide block */}}
@@ -53,6 +71,12 @@ const Slides_tmpl = `
   {{if .PlayEnabled}}
   <script src='/static/play.js'></script>
   {{end}}
+  {{if not .Inline}}
+  <script src='/static/sync.js'></script>
+  {{end}}
+  {{if .LiveReload}}
+  <script src='/static/reload.js'></script>
+  {{end}}
 </html>
 {{end}}
 
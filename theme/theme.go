@@ -0,0 +1,302 @@
+package theme
+
+import (
+	"carousel/static"
+	"carousel/templates"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Default is the theme used when none is given.
+	Default = "light"
+
+	// URLPrefix is the path under which files of themes are served.
+	URLPrefix = "/static/themes/"
+
+	_DEFAULT_LAYOUT = "layout-widescreen"
+)
+
+// Files in a theme directory.
+const (
+	ConfigFile = "theme.json"
+	CSSFile    = "theme.css"
+	SlidesFile = "slides.tmpl"
+	ActionFile = "action.tmpl"
+)
+
+// Theme decides the look of slides. A theme is either built in the binary
+// or a directory which may contain:
+//
+//	theme.json   settings, such as {"Layout": "layout-faux-widescreen"}
+//	theme.css    style sheet loaded after the default one
+//	slides.tmpl  template replacing the page of slides ("root")
+//	action.tmpl  templates overriding ones of elements of slides
+//
+// Files which theme.css refers by relative URLs, such as fonts and images,
+// are served under the URL of the theme. Other files in the directory are
+// not, as the directory may be given by slides of anyone.
+type Theme struct {
+	Name   string // key of the theme in its URL
+	Layout string // class of the slides section
+
+	SlidesTmpl string
+	ActionTmpl string
+	CSS        string
+
+	dir    string          // empty for built-in themes
+	assets map[string]bool // files referred by the style sheet, as "/" + slash separated paths
+}
+
+var builtins = map[string]string{
+	"light":         "",
+	"dark":          static.Dark_css,
+	"high-contrast": static.HighContrast_css,
+}
+
+// theme directories loaded so far, to serve their files by the key
+var (
+	mu   sync.Mutex
+	dirs = make(map[string]string) // key to directory
+	keys = make(map[string]string) // directory to key
+)
+
+// Builtins returns names of the built-in themes.
+func Builtins() []string {
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// IsBuiltin tells whether the name is of a built-in theme.
+func IsBuiltin(name string) bool {
+	_, ok := builtins[name]
+	return ok
+}
+
+// Load returns the theme of the name, which is either a built-in theme or a
+// directory. A relative directory is resolved from base.
+func Load(name, base string) (*Theme, error) {
+	if name == "" {
+		name = Default
+	}
+
+	if css, ok := builtins[name]; ok {
+		return newBuiltin(name, css), nil
+	}
+
+	dir := name
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(base, dir)
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		return nil, fmt.Errorf("theme '%s' is neither built-in (%s) nor a directory", name, strings.Join(Builtins(), ", "))
+	}
+
+	return loadDir(register(dir), dir)
+}
+
+// Lookup returns the theme loaded before with the key in its URL.
+func Lookup(key string) (*Theme, error) {
+	if css, ok := builtins[key]; ok {
+		return newBuiltin(key, css), nil
+	}
+
+	mu.Lock()
+	dir, ok := dirs[key]
+	mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown theme '%s'", key)
+	}
+
+	return loadDir(key, dir)
+}
+
+func newBuiltin(name, css string) *Theme {
+	return &Theme{
+		Name:       name,
+		Layout:     _DEFAULT_LAYOUT,
+		SlidesTmpl: templates.Slides_tmpl,
+		ActionTmpl: templates.Action_tmpl,
+		CSS:        css,
+	}
+}
+
+// register gives the directory a key which is unique among themes.
+func register(dir string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if key, ok := keys[dir]; ok {
+		return key
+	}
+
+	base := filepath.Base(dir)
+	key := base
+	for i := 2; ; i++ {
+		_, isBuiltin := builtins[key]
+		_, taken := dirs[key]
+		if !isBuiltin && !taken {
+			break
+		}
+		key = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	dirs[key] = dir
+	keys[dir] = key
+
+	return key
+}
+
+func loadDir(key, dir string) (*Theme, error) {
+	t := newBuiltin(key, "")
+	t.dir = dir
+
+	if b, err := ioutil.ReadFile(filepath.Join(dir, ConfigFile)); err == nil {
+		var conf struct {
+			Layout string
+		}
+		if err := json.Unmarshal(b, &conf); err != nil {
+			return nil, fmt.Errorf("while reading %s of theme '%s': %v", ConfigFile, key, err)
+		}
+		if conf.Layout != "" {
+			t.Layout = conf.Layout
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for name, s := range map[string]*string{
+		CSSFile:    &t.CSS,
+		SlidesFile: &t.SlidesTmpl,
+		ActionFile: &t.ActionTmpl,
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		*s = string(b)
+	}
+
+	t.assets = cssAssets(t.CSS, t.CSSURL())
+
+	return t, nil
+}
+
+var cssURLRE = regexp.MustCompile(`url\(\s*['"]?([^'")]+?)['"]?\s*\)`)
+
+// cssAssets returns files of the theme directory which the style sheet
+// served at cssURL refers.
+func cssAssets(css, cssURL string) map[string]bool {
+	assets := make(map[string]bool)
+
+	for _, m := range cssURLRE.FindAllStringSubmatch(css, -1) {
+		if name, ok := resolveAsset(m[1], cssURL); ok {
+			assets[name] = true
+		}
+	}
+
+	return assets
+}
+
+// resolveAsset returns the file of the theme directory which the URL in
+// the style sheet served at cssURL points to, as browsers resolve it. The
+// query of such as "font.eot?#iefix" is dropped.
+func resolveAsset(ref, cssURL string) (string, bool) {
+	base, err := url.Parse(cssURL)
+	if err != nil {
+		return "", false
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+
+	dir := path.Dir(base.Path) + "/"
+	p := base.ResolveReference(u).Path
+	if !strings.HasPrefix(p, dir) {
+		return "", false
+	}
+
+	return "/" + strings.TrimPrefix(p, dir), true
+}
+
+// IsBuiltin tells whether the theme is built in the binary.
+func (t *Theme) IsBuiltin() bool {
+	return t.dir == ""
+}
+
+// CSSURL returns the URL of the style sheet of the theme, or an empty
+// string if the theme has none.
+func (t *Theme) CSSURL() string {
+	if t.CSS == "" {
+		return ""
+	}
+
+	return URLPrefix + t.Name + "/" + CSSFile
+}
+
+// File returns the local path of a file in the theme directory. name is a
+// slash separated path relative to the directory. Only the style sheet and
+// files it refers are found.
+func (t *Theme) File(name string) (string, bool) {
+	if t.dir == "" {
+		return "", false
+	}
+
+	name = path.Clean("/" + name)
+	if name != "/"+CSSFile && !t.assets[name] {
+		return "", false
+	}
+
+	return filepath.Join(t.dir, filepath.FromSlash(name)), true
+}
+
+// AssetFile returns the local path of the file which the URL in the style
+// sheet points to, if it is in the theme directory.
+func (t *Theme) AssetFile(ref string) (string, bool) {
+	name, ok := resolveAsset(ref, t.CSSURL())
+	if !ok {
+		return "", false
+	}
+
+	return t.File(name)
+}
+
+// Files returns files which make up the theme, to watch them for changes.
+func (t *Theme) Files() (files []string) {
+	if t.dir == "" {
+		return nil
+	}
+
+	for _, name := range []string{ConfigFile, CSSFile, SlidesFile, ActionFile} {
+		files = append(files, filepath.Join(t.dir, name))
+	}
+
+	return
+}